@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// HandleAction processes resign, offer_draw, accept_draw and decline_draw
+// from one of the players. Every action is recorded in the move history.
+func (g *Game) HandleAction(player *Player, action string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.Status != "playing" {
+		player.SendError("The game is already over.")
+		return
+	}
+	playerNum := g.numberOf(player)
+	opponentNum := 3 - playerNum
+
+	switch action {
+	case "resign":
+		g.recordAction(player, playerNum, action)
+		log.Printf("Player %s resigned game %s.", player.Username, g.ID)
+		g.endGame(opponentNum, ReasonResignation)
+
+	case "offer_draw":
+		if g.IsBot {
+			player.SendError("The bot plays every game to the end.")
+			return
+		}
+		if g.drawOffer == opponentNum {
+			player.SendError("Your opponent has offered a draw; accept it instead.")
+			return
+		}
+		if g.drawOffer == playerNum || g.offeredDraw[playerNum] {
+			player.SendError("You can offer a draw again after your next move.")
+			return
+		}
+		g.recordAction(player, playerNum, action)
+		g.drawOffer = playerNum
+		g.offeredDraw[playerNum] = true
+		log.Printf("Player %s offered a draw in game %s.", player.Username, g.ID)
+
+	case "accept_draw":
+		if g.drawOffer != opponentNum {
+			player.SendError("Your opponent hasn't offered a draw.")
+			return
+		}
+		g.recordAction(player, playerNum, action)
+		log.Printf("Player %s accepted a draw in game %s.", player.Username, g.ID)
+		g.endGame(Empty, ReasonAgreement)
+
+	case "decline_draw":
+		if g.drawOffer != opponentNum {
+			player.SendError("Your opponent hasn't offered a draw.")
+			return
+		}
+		g.recordAction(player, playerNum, action)
+		g.drawOffer = 0
+		log.Printf("Player %s declined a draw in game %s.", player.Username, g.ID)
+		if opponent := g.opponentOf(player); opponent != nil {
+			opponent.SendMessage("draw_declined", map[string]interface{}{"by": player.Username})
+		}
+	}
+
+	g.BroadcastState()
+}
+
+// recordAction adds an action to the move history. The caller must hold
+// g.mutex.
+func (g *Game) recordAction(player *Player, playerNum int, action string) {
+	move := Move{Column: -1, Row: -1, Player: playerNum, Time: time.Now(), Action: action}
+	g.Moves = append(g.Moves, move)
+
+	go ProduceEvent("game_action", map[string]interface{}{
+		"gameId":   g.ID,
+		"player":   player.Username,
+		"action":   action,
+		"gameTime": move.Time.Unix(),
+	})
+}
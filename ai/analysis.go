@@ -0,0 +1,140 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"hello-go/engine"
+)
+
+// ScoredMove is a column with its score for the player making the move.
+type ScoredMove struct {
+	Column int `json:"column"`
+	Score  int `json:"score"`
+}
+
+// ScoreMoves scores every legal move for the player to move, best first.
+// Unlike Search, which only proves the best move is best, each move is
+// searched with a full window so the scores can be compared with each other.
+// It deepens iteratively like Search and returns the scores of the deepest
+// iteration that finished within the time budget, with its depth.
+func (s *Searcher) ScoreMoves(pos engine.Position, player int) ([]ScoredMove, int) {
+	st := &search{order: pos.Variant().CenterOrder(), table: s.Table}
+	if s.TimeBudget > 0 {
+		st.deadline = time.Now().Add(s.TimeBudget)
+	}
+
+	v := pos.Variant()
+	maxDepth := min(max(s.Depth, 1), v.Cols*v.Rows-pos.MoveCount())
+
+	var scored []ScoredMove
+	depth := 0
+	for d := 1; d <= maxDepth; d++ {
+		moves := make([]ScoredMove, 0, v.Cols)
+		for _, c := range st.order {
+			if !pos.CanPlay(c) {
+				continue
+			}
+			score := WinScore - 1
+			if !pos.IsWinningMove(c, player) {
+				pos.Play(c, player)
+				score = -st.negamax(&pos, 3-player, d-1, -WinScore-1, WinScore+1, 1)
+				pos.Undo(c)
+			}
+			moves = append(moves, ScoredMove{Column: c, Score: score})
+		}
+		if st.aborted {
+			break
+		}
+		// Stable, so equal scores stay in center-first order
+		sort.SliceStable(moves, func(i, j int) bool { return moves[i].Score > moves[j].Score })
+		scored, depth = moves, d
+	}
+	return scored, depth
+}
+
+// Quality grades a move by how much worse it is than the best move.
+type Quality string
+
+const (
+	QualityBest       Quality = "best"
+	QualityGood       Quality = "good"
+	QualityInaccuracy Quality = "inaccuracy"
+	QualityBlunder    Quality = "blunder"
+)
+
+// Score losses, in Evaluate units, up to which a move still counts as good
+// or as an inaccuracy. An open three is worth threeWeight; anything that
+// gives away a forced win or allows a forced loss is a blunder.
+const (
+	goodLoss       = threeWeight / 2
+	inaccuracyLoss = 3 * threeWeight
+)
+
+// GradeMove returns the quality of a move that scored played when the best
+// move scored best.
+func GradeMove(played, best int) Quality {
+	loss := best - played
+	switch {
+	case loss <= 0:
+		return QualityBest
+	case best > winThreshold && played <= winThreshold:
+		return QualityBlunder // Threw away a forced win
+	case played < -winThreshold && best >= -winThreshold:
+		return QualityBlunder // Walked into a forced loss
+	case loss <= goodLoss:
+		return QualityGood
+	case loss <= inaccuracyLoss:
+		return QualityInaccuracy
+	}
+	return QualityBlunder
+}
+
+// MoveAnalysis is the verdict on one move of a game.
+type MoveAnalysis struct {
+	MoveNumber int     `json:"moveNumber"`
+	Player     int     `json:"player"`
+	Column     int     `json:"column"`
+	Score      int     `json:"score"` // From the moving player's point of view
+	BestColumn int     `json:"bestColumn"`
+	BestScore  int     `json:"bestScore"`
+	Quality    Quality `json:"quality"`
+	Depth      int     `json:"depth"`
+}
+
+// Analyze grades every move of a game played from the empty board, Player1
+// first, by scoring all alternatives with the searcher.
+func (s *Searcher) Analyze(v engine.Variant, moves []int) ([]MoveAnalysis, error) {
+	pos, err := engine.NewPosition(v)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis := make([]MoveAnalysis, 0, len(moves))
+	player := engine.Player1
+	for i, col := range moves {
+		if !pos.CanPlay(col) {
+			return nil, fmt.Errorf("move %d: column %d cannot be played", i+1, col+1)
+		}
+
+		scored, depth := s.ScoreMoves(*pos, player)
+		a := MoveAnalysis{MoveNumber: i + 1, Player: player, Column: col, Depth: depth}
+		for _, m := range scored {
+			if m.Column == col {
+				a.Score = m.Score
+			}
+		}
+		a.BestColumn, a.BestScore = scored[0].Column, scored[0].Score
+		a.Quality = GradeMove(a.Score, a.BestScore)
+		analysis = append(analysis, a)
+
+		won := pos.IsWinningMove(col, player)
+		pos.Play(col, player)
+		if won && i < len(moves)-1 {
+			return nil, fmt.Errorf("move %d: the game is already over", i+2)
+		}
+		player = 3 - player
+	}
+	return analysis, nil
+}
@@ -0,0 +1,160 @@
+package ai
+
+//go:generate go run ../cmd/genbook -depth 5 -out book.bin
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sync"
+
+	"hello-go/engine"
+)
+
+// bookMagic starts every opening book file.
+var bookMagic = [4]byte{'C', '4', 'B', 'K'}
+
+const bookVersion = 1
+
+// bookHeader precedes the entries of a book file.
+type bookHeader struct {
+	Magic    [4]byte
+	Version  uint8
+	Cols     uint8
+	Rows     uint8
+	MaxMoves uint8
+	Count    uint32
+}
+
+//go:embed book.bin
+var embeddedBook []byte
+
+// Book holds exact solver scores for positions up to a number of moves, so
+// the hardest, earliest positions are answered instantly. A position and its
+// mirror image share an entry.
+type Book struct {
+	maxMoves int
+	scores   map[uint64]int8
+}
+
+// NewBook creates an empty book for positions with up to maxMoves discs.
+func NewBook(maxMoves int) *Book {
+	return &Book{maxMoves: maxMoves, scores: make(map[uint64]int8)}
+}
+
+var (
+	parsedBook     *Book
+	parsedBookOnce sync.Once
+)
+
+// EmbeddedBook returns the opening book compiled into the binary, generated
+// by cmd/genbook.
+func EmbeddedBook() *Book {
+	parsedBookOnce.Do(func() {
+		b, err := ReadBook(bytes.NewReader(embeddedBook))
+		if err != nil {
+			log.Printf("Opening book unavailable: %v", err)
+			b = NewBook(-1)
+		}
+		parsedBook = b
+	})
+	return parsedBook
+}
+
+// BookKey identifies the position, as seen by the player to move, in a book.
+func BookKey(pos engine.Position, player int) (uint64, error) {
+	p, err := newSolverPos(&pos, player)
+	if err != nil {
+		return 0, err
+	}
+	return canonicalKey(p.key()), nil
+}
+
+// MaxMoves returns the largest number of discs of the positions in the book.
+func (b *Book) MaxMoves() int {
+	return b.maxMoves
+}
+
+// Len returns the number of positions in the book.
+func (b *Book) Len() int {
+	return len(b.scores)
+}
+
+// Add records the solved score of a position.
+func (b *Book) Add(pos engine.Position, player int, sol Solution) error {
+	key, err := BookKey(pos, player)
+	if err != nil {
+		return err
+	}
+	b.scores[key] = int8(sol.Score)
+	return nil
+}
+
+// lookup returns the score stored for a solver position key.
+func (b *Book) lookup(key uint64, moves int) (int, bool) {
+	if b == nil || moves > b.maxMoves {
+		return 0, false
+	}
+	score, ok := b.scores[canonicalKey(key)]
+	return int(score), ok
+}
+
+// WriteTo writes the book in its binary format: a header followed by
+// (key, score) pairs sorted by key, so regenerating a book is reproducible.
+func (b *Book) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := bookHeader{bookMagic, bookVersion, solverWidth, solverHeight, uint8(b.maxMoves), uint32(len(b.scores))}
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return 0, err
+	}
+
+	keys := make([]uint64, 0, len(b.scores))
+	for key := range b.scores {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if err := binary.Write(bw, binary.LittleEndian, key); err != nil {
+			return 0, err
+		}
+		if err := bw.WriteByte(byte(b.scores[key])); err != nil {
+			return 0, err
+		}
+	}
+	return int64(binary.Size(header) + 9*len(keys)), bw.Flush()
+}
+
+// ReadBook parses a book written by WriteTo.
+func ReadBook(r io.Reader) (*Book, error) {
+	br := bufio.NewReader(r)
+	var header bookHeader
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("read book header: %w", err)
+	}
+	if header.Magic != bookMagic || header.Version != bookVersion {
+		return nil, errors.New("not an opening book file")
+	}
+	if header.Cols != solverWidth || header.Rows != solverHeight {
+		return nil, ErrUnsupportedVariant
+	}
+
+	b := NewBook(int(header.MaxMoves))
+	for i := uint32(0); i < header.Count; i++ {
+		var key uint64
+		if err := binary.Read(br, binary.LittleEndian, &key); err != nil {
+			return nil, fmt.Errorf("read book entry: %w", err)
+		}
+		score, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("read book entry: %w", err)
+		}
+		b.scores[key] = int8(score)
+	}
+	return b, nil
+}
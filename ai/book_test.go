@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"testing"
+	"time"
+
+	"hello-go/engine"
+)
+
+func TestEmbeddedBookIsComplete(t *testing.T) {
+	book := EmbeddedBook()
+	if book.MaxMoves() < 0 {
+		t.Fatal("the embedded book failed to load")
+	}
+
+	type node struct {
+		pos    engine.Position
+		player int
+	}
+	level := []node{{pos: *engine.NewStandardPosition(), player: engine.Player1}}
+	for moves := 0; moves <= book.MaxMoves(); moves++ {
+		var next []node
+		missing := 0
+		for _, n := range level {
+			key, err := BookKey(n.pos, n.player)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := book.lookup(key, moves); !ok {
+				missing++
+			}
+			for _, col := range n.pos.LegalMoves() {
+				if n.pos.IsWinningMove(col, n.player) {
+					continue
+				}
+				child := node{pos: n.pos, player: 3 - n.player}
+				child.pos.Play(col, n.player)
+				next = append(next, child)
+			}
+		}
+		if missing > 0 {
+			t.Errorf("%d of %d positions with %d discs are missing from the book", missing, len(level), moves)
+		}
+		level = next
+	}
+}
+
+func TestSolveEmptyBoardFromBook(t *testing.T) {
+	// The first player wins with their last disc, on the 41st move
+	want := Solution{Score: 1, Outcome: OutcomeWin, Distance: 41}
+
+	deadline := time.Now().Add(time.Second)
+	got, err := DefaultSolver().Solve(*engine.NewStandardPosition(), engine.Player1, deadline)
+	if err != nil {
+		t.Fatalf("Solve(empty board) error: %v", err)
+	}
+	if got != want {
+		t.Errorf("Solve(empty board) = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package ai
+
+import (
+	"math/bits"
+	"sync"
+
+	"hello-go/engine"
+)
+
+// Heuristic weights for windows of Connect cells that only one player has
+// discs in. A window one disc short of a line is an open three on 7x6/4.
+const (
+	threeWeight  = 50
+	twoWeight    = 5
+	centerWeight = 3
+)
+
+var windowCache sync.Map // engine.Variant -> []uint64
+
+// windows returns the bitboard masks of every line of Connect cells on the
+// variant's board.
+func windows(v engine.Variant) []uint64 {
+	if cached, ok := windowCache.Load(v); ok {
+		return cached.([]uint64)
+	}
+
+	pos, _ := engine.NewPosition(v)
+	var masks []uint64
+	directions := [4][2]int{{1, 0}, {0, 1}, {1, 1}, {1, -1}} // (col, height) steps
+	for c := 0; c < v.Cols; c++ {
+		for h := 0; h < v.Rows; h++ {
+			for _, d := range directions {
+				endC, endH := c+d[0]*(v.Connect-1), h+d[1]*(v.Connect-1)
+				if endC < 0 || endC >= v.Cols || endH < 0 || endH >= v.Rows {
+					continue
+				}
+				var mask uint64
+				for i := 0; i < v.Connect; i++ {
+					mask |= pos.Bit(c+d[0]*i, h+d[1]*i)
+				}
+				masks = append(masks, mask)
+			}
+		}
+	}
+
+	windowCache.Store(v, masks)
+	return masks
+}
+
+// Evaluate scores a position from the player's point of view without
+// searching: positive favours the player, negative the opponent.
+func Evaluate(pos *engine.Position, player int) int {
+	v := pos.Variant()
+	mine, theirs := pos.Mask(player), pos.Mask(3-player)
+
+	score := 0
+	for _, w := range windows(v) {
+		m, t := bits.OnesCount64(w&mine), bits.OnesCount64(w&theirs)
+		switch {
+		case t == 0:
+			score += windowScore(m, v.Connect)
+		case m == 0:
+			score -= windowScore(t, v.Connect)
+		}
+	}
+
+	// Discs in the center column take part in the most lines
+	center := v.Cols / 2
+	for h := 0; h < pos.Height(center); h++ {
+		bit := pos.Bit(center, h)
+		if mine&bit != 0 {
+			score += centerWeight
+		} else if theirs&bit != 0 {
+			score -= centerWeight
+		}
+	}
+	return score
+}
+
+// windowScore values a window holding n discs of a single player.
+func windowScore(n, connect int) int {
+	if n == 0 {
+		return 0
+	}
+	switch n {
+	case connect - 1:
+		return threeWeight
+	case connect - 2:
+		return twoWeight
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"math/rand"
+
+	"hello-go/engine"
+)
+
+// Heuristic is the original bot logic: win if possible, otherwise block the
+// opponent's win, otherwise play the most central column that doesn't hand
+// the opponent a win, otherwise play randomly.
+type Heuristic struct {
+	rng *rand.Rand
+}
+
+// ChooseMove implements Strategy.
+func (h *Heuristic) ChooseMove(pos engine.Position, player int) (int, *Evaluation) {
+	opponent := 3 - player
+	moves := pos.LegalMoves()
+
+	// 1. Check for immediate winning moves
+	for _, c := range moves {
+		if pos.IsWinningMove(c, player) {
+			return c, &Evaluation{Score: WinScore - 1, Outcome: OutcomeWin, Distance: 1}
+		}
+	}
+
+	// 2. Check for immediate winning moves for the opponent (and block them)
+	for _, c := range moves {
+		if pos.IsWinningMove(c, opponent) {
+			return c, nil
+		}
+	}
+
+	// 3. Try to play in the center
+	for _, c := range pos.Variant().CenterOrder() {
+		if !pos.CanPlay(c) {
+			continue
+		}
+		// Don't set up the opponent for a win
+		if givesAwayWin(&pos, c, player) {
+			continue
+		}
+		return c, nil
+	}
+
+	// 4. Fallback: play any valid random move
+	return moves[h.rng.Intn(len(moves))], nil
+}
+
+// givesAwayWin reports whether playing col lets the opponent win by dropping
+// a disc directly on top of it.
+func givesAwayWin(pos *engine.Position, col, player int) bool {
+	pos.Play(col, player)
+	defer pos.Undo(col)
+
+	if !pos.CanPlay(col) {
+		return false // Don't check if we're at the very top
+	}
+	return pos.IsWinningMove(col, 3-player)
+}
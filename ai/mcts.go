@@ -0,0 +1,191 @@
+package ai
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"hello-go/engine"
+)
+
+// defaultIterations is the number of playouts MCTS runs per move when
+// neither Iterations nor TimeBudget is set.
+const defaultIterations = 10000
+
+// uctExploration is the UCT exploration constant, sqrt(2) for rewards in
+// [0, 1].
+var uctExploration = math.Sqrt2
+
+// MCTS chooses moves with Monte Carlo Tree Search: it grows a game tree by
+// UCT selection, scores new nodes with uniformly random playouts and plays
+// the most visited move. Its play is strong in tactics it has sampled and
+// loose elsewhere, which feels more human than a fixed search.
+//
+// With a seeded Rand and only Iterations set, its moves are deterministic.
+type MCTS struct {
+	Iterations int           // Playouts per move; zero means until TimeBudget
+	TimeBudget time.Duration // Time to spend per move; zero means no limit
+	rng        *rand.Rand
+}
+
+// NewMCTS creates an MCTS strategy. A nil rng is seeded from the clock.
+func NewMCTS(iterations int, budget time.Duration, rng *rand.Rand) *MCTS {
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return &MCTS{Iterations: iterations, TimeBudget: budget, rng: rng}
+}
+
+// mctsNode is a position in the search tree, reached by move.
+type mctsNode struct {
+	parent   *mctsNode
+	children []*mctsNode
+	untried  []int // Legal moves without a child yet
+	move     int
+	player   int  // Player who played move
+	terminal bool // The game ended with move
+	visits   int
+	reward   float64 // Sum of playout results for player: 1 win, 0.5 draw
+}
+
+func newMCTSNode(parent *mctsNode, pos *engine.Position, move, player int, terminal bool) *mctsNode {
+	n := &mctsNode{parent: parent, move: move, player: player, terminal: terminal}
+	if !terminal {
+		n.untried = pos.LegalMoves()
+	}
+	return n
+}
+
+// ChooseMove implements Strategy.
+func (m *MCTS) ChooseMove(pos engine.Position, player int) (int, *Evaluation) {
+	iterations := m.Iterations
+	if iterations <= 0 && m.TimeBudget <= 0 {
+		iterations = defaultIterations
+	}
+	var deadline time.Time
+	if m.TimeBudget > 0 {
+		deadline = time.Now().Add(m.TimeBudget)
+	}
+
+	// The root's player is the one who moved last, so its children are
+	// scored for the player to move.
+	root := newMCTSNode(nil, &pos, -1, 3-player, false)
+	for i := 0; iterations <= 0 || i < iterations; i++ {
+		// Checking the clock every playout would cost more than the playout
+		if !deadline.IsZero() && i%64 == 0 && time.Now().After(deadline) {
+			break
+		}
+		m.iterate(root, pos)
+	}
+
+	var best *mctsNode
+	for _, child := range root.children {
+		if best == nil || child.visits > best.visits {
+			best = child
+		}
+	}
+	if best == nil {
+		// No time for a single playout
+		moves := pos.LegalMoves()
+		return moves[m.rng.Intn(len(moves))], nil
+	}
+	// Score the expected result from -100 (certain loss) to 100 (certain win)
+	score := int(math.Round((2*best.reward/float64(best.visits) - 1) * 100))
+	return best.move, &Evaluation{Score: score, Depth: treeDepth(best) + 1}
+}
+
+// iterate runs one selection, expansion, playout and backpropagation step.
+func (m *MCTS) iterate(root *mctsNode, pos engine.Position) {
+	n := root
+	for len(n.untried) == 0 && len(n.children) > 0 {
+		n = n.selectChild()
+		pos.Play(n.move, n.player)
+	}
+
+	if len(n.untried) > 0 {
+		i := m.rng.Intn(len(n.untried))
+		move := n.untried[i]
+		n.untried[i] = n.untried[len(n.untried)-1]
+		n.untried = n.untried[:len(n.untried)-1]
+
+		player := 3 - n.player
+		won := pos.IsWinningMove(move, player)
+		pos.Play(move, player)
+		child := newMCTSNode(n, &pos, move, player, won || pos.IsFull())
+		n.children = append(n.children, child)
+		n = child
+	}
+
+	winner := engine.Empty
+	if n.terminal {
+		if pos.IsWin(n.player) {
+			winner = n.player
+		}
+	} else {
+		winner = m.playout(pos, 3-n.player)
+	}
+
+	for ; n != nil; n = n.parent {
+		n.visits++
+		switch winner {
+		case n.player:
+			n.reward++
+		case engine.Empty:
+			n.reward += 0.5
+		}
+	}
+}
+
+// selectChild returns the child with the highest UCT value.
+func (n *mctsNode) selectChild() *mctsNode {
+	logVisits := math.Log(float64(n.visits))
+	var best *mctsNode
+	bestValue := math.Inf(-1)
+	for _, child := range n.children {
+		visits := float64(child.visits)
+		value := child.reward/visits + uctExploration*math.Sqrt(logVisits/visits)
+		if value > bestValue {
+			best, bestValue = child, value
+		}
+	}
+	return best
+}
+
+// playout plays uniformly random moves until the game ends and returns the
+// winner, or Empty for a draw.
+func (m *MCTS) playout(pos engine.Position, player int) int {
+	cols := pos.Variant().Cols
+	moves := make([]int, 0, cols)
+	for !pos.IsFull() {
+		moves = moves[:0]
+		for c := 0; c < cols; c++ {
+			if pos.CanPlay(c) {
+				moves = append(moves, c)
+			}
+		}
+		col := moves[m.rng.Intn(len(moves))]
+		if pos.IsWinningMove(col, player) {
+			return player
+		}
+		pos.Play(col, player)
+		player = 3 - player
+	}
+	return engine.Empty
+}
+
+// treeDepth returns how many plies the tree extends below n along its most
+// visited line.
+func treeDepth(n *mctsNode) int {
+	depth := 0
+	for len(n.children) > 0 {
+		best := n.children[0]
+		for _, child := range n.children[1:] {
+			if child.visits > best.visits {
+				best = child
+			}
+		}
+		n = best
+		depth++
+	}
+	return depth
+}
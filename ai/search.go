@@ -0,0 +1,243 @@
+// Package ai contains the bot's move search, independent of the game server
+// so the same players can be used by tools.
+package ai
+
+import (
+	"time"
+
+	"hello-go/engine"
+)
+
+const (
+	// WinScore is the score of a won position; faster wins score higher.
+	WinScore = 1000000
+
+	// winThreshold separates forced wins and losses from heuristic scores.
+	winThreshold = WinScore - 1000
+
+	// player2Key is mixed into hashes when Player2 is to move, since the
+	// same discs can be searched for either side.
+	player2Key = 0x9e3779b97f4a7c15
+)
+
+// Searcher picks moves with an iteratively deepened negamax search and
+// alpha-beta pruning, falling back to Evaluate at the horizon.
+type Searcher struct {
+	Depth      int                 // Maximum plies to look ahead
+	TimeBudget time.Duration       // Zero means no limit
+	Table      *TranspositionTable // Optional, may be shared between searchers
+}
+
+// Result is the outcome of a search.
+type Result struct {
+	Column   int   `json:"column"`
+	Score    int   `json:"score"`    // From the searching player's point of view
+	Depth    int   `json:"depth"`    // Deepest fully searched iteration
+	Complete bool  `json:"complete"` // False if the time budget ran out
+	Nodes    int64 `json:"nodes"`
+}
+
+// search holds the state of a single Search call.
+type search struct {
+	order    []int
+	table    *TranspositionTable
+	deadline time.Time
+	nodes    int64
+	aborted  bool
+}
+
+// Search returns the best column for the player to move in pos. The position
+// must have at least one legal move.
+//
+// It searches one ply deeper on each iteration, so when the time budget runs
+// out the result of the deepest finished iteration is returned.
+func (s *Searcher) Search(pos engine.Position, player int) Result {
+	st := &search{order: pos.Variant().CenterOrder(), table: s.Table}
+	if s.TimeBudget > 0 {
+		st.deadline = time.Now().Add(s.TimeBudget)
+	}
+
+	v := pos.Variant()
+	maxDepth := min(max(s.Depth, 1), v.Cols*v.Rows-pos.MoveCount())
+
+	result := Result{Column: -1}
+	for _, c := range st.order {
+		if pos.CanPlay(c) {
+			result.Column = c // Always have a legal move to return
+			break
+		}
+	}
+
+	for depth := 1; depth <= maxDepth; depth++ {
+		col, score := st.root(&pos, player, depth, result.Column)
+		if st.aborted {
+			break
+		}
+		result.Column, result.Score, result.Depth = col, score, depth
+		if score > winThreshold || score < -winThreshold {
+			break // Forced result, deeper search cannot change it
+		}
+	}
+
+	result.Complete = !st.aborted
+	result.Nodes = st.nodes
+	return result
+}
+
+// root searches every move at the root, trying first the best move of the
+// previous iteration.
+func (st *search) root(pos *engine.Position, player, depth, first int) (int, int) {
+	bestCol, bestScore := first, -WinScore-1
+	alpha, beta := -WinScore-1, WinScore+1
+	for _, c := range st.moveOrder(first) {
+		if !pos.CanPlay(c) {
+			continue
+		}
+
+		var score int
+		if pos.IsWinningMove(c, player) {
+			score = WinScore - 1
+		} else {
+			pos.Play(c, player)
+			score = -st.negamax(pos, 3-player, depth-1, -beta, -alpha, 1)
+			pos.Undo(c)
+		}
+		if st.aborted {
+			break
+		}
+
+		if score > bestScore {
+			bestCol, bestScore = c, score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	return bestCol, bestScore
+}
+
+// negamax returns the score of pos for the player to move.
+func (st *search) negamax(pos *engine.Position, player, depth, alpha, beta, ply int) int {
+	st.nodes++
+	if st.nodes&1023 == 0 && !st.deadline.IsZero() && time.Now().After(st.deadline) {
+		st.aborted = true
+	}
+	if st.aborted {
+		return 0
+	}
+
+	if pos.IsFull() {
+		return 0 // Draw
+	}
+
+	// A win this move beats anything else the search could find
+	for _, c := range st.order {
+		if pos.CanPlay(c) && pos.IsWinningMove(c, player) {
+			return WinScore - ply - 1
+		}
+	}
+
+	if depth == 0 {
+		return Evaluate(pos, player)
+	}
+
+	key := pos.Hash()
+	if player == engine.Player2 {
+		key ^= player2Key
+	}
+	ttMove := -1
+	if st.table != nil {
+		if e, ok := st.table.Probe(key); ok {
+			ttMove = int(e.Move)
+			if int(e.Depth) >= depth {
+				score := fromTT(e.Score, ply)
+				switch e.Bound {
+				case BoundExact:
+					return score
+				case BoundLower:
+					alpha = max(alpha, score)
+				case BoundUpper:
+					beta = min(beta, score)
+				}
+				if alpha >= beta {
+					return score
+				}
+			}
+		}
+	}
+
+	alphaOrig := alpha
+	best, bestMove := -WinScore-1, -1
+	for _, c := range st.moveOrder(ttMove) {
+		if !pos.CanPlay(c) {
+			continue
+		}
+		pos.Play(c, player)
+		score := -st.negamax(pos, 3-player, depth-1, -beta, -alpha, ply+1)
+		pos.Undo(c)
+		if st.aborted {
+			return 0
+		}
+
+		if score > best {
+			best, bestMove = score, c
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+
+	if st.table != nil {
+		bound := BoundExact
+		if best <= alphaOrig {
+			bound = BoundUpper
+		} else if best >= beta {
+			bound = BoundLower
+		}
+		st.table.Store(TTEntry{Key: key, Score: toTT(best, ply), Move: int8(bestMove), Depth: int8(depth), Bound: bound})
+	}
+	return best
+}
+
+// moveOrder returns the columns center first, with first moved to the front
+// when it is a valid column.
+func (st *search) moveOrder(first int) []int {
+	if first < 0 || first == st.order[0] {
+		return st.order
+	}
+	order := make([]int, 0, len(st.order))
+	order = append(order, first)
+	for _, c := range st.order {
+		if c != first {
+			order = append(order, c)
+		}
+	}
+	return order
+}
+
+// toTT converts a win or loss score from distance-from-root to
+// distance-from-this-node, so it stays valid wherever the entry is reused.
+func toTT(score, ply int) int32 {
+	switch {
+	case score > winThreshold:
+		score += ply
+	case score < -winThreshold:
+		score -= ply
+	}
+	return int32(score)
+}
+
+// fromTT reverses toTT for a node at the given ply.
+func fromTT(score int32, ply int) int {
+	s := int(score)
+	switch {
+	case s > winThreshold:
+		s -= ply
+	case s < -winThreshold:
+		s += ply
+	}
+	return s
+}
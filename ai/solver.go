@@ -0,0 +1,456 @@
+package ai
+
+import (
+	"errors"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hello-go/engine"
+)
+
+// The solver only handles the standard board, where a position and its
+// occupancy mask fit in 49 bits and scores fit in a byte.
+const (
+	solverWidth  = 7
+	solverHeight = 6
+	solverCells  = solverWidth * solverHeight
+
+	minSolverScore = -solverCells/2 + 3
+	maxSolverScore = (solverCells+1)/2 - 3
+)
+
+var (
+	ErrUnsupportedVariant = errors.New("the solver only supports 7x6 boards with four in a row")
+	ErrGameOver           = errors.New("the game is already over")
+	ErrTimeout            = errors.New("the solver ran out of time")
+)
+
+// Outcome is the game-theoretic result for the player to move.
+type Outcome string
+
+const (
+	OutcomeWin  Outcome = "win"
+	OutcomeLoss Outcome = "loss"
+	OutcomeDraw Outcome = "draw"
+)
+
+// Solution is the exact value of a position with perfect play by both sides.
+type Solution struct {
+	// Score is positive if the player to move wins, negative if they lose
+	// and zero for a draw. Its magnitude is the number of stones the winner
+	// still has in hand when the game ends, so faster wins score higher.
+	Score    int     `json:"score"`
+	Outcome  Outcome `json:"outcome"`
+	Distance int     `json:"distance"` // Plies until the game ends
+}
+
+// newSolution converts a score into a Solution for a position with the
+// given number of moves played.
+func newSolution(score, moves int) Solution {
+	sol := Solution{Score: score}
+	switch {
+	case score > 0:
+		// The player to move wins with their (22 - score)th stone
+		sol.Outcome = OutcomeWin
+		sol.Distance = 2*(solverCells/2+1-score-moves/2) - 1
+	case score < 0:
+		// The opponent wins with their (22 + score)th stone
+		sol.Outcome = OutcomeLoss
+		sol.Distance = 2 * (solverCells/2 + 1 + score - (moves+1)/2)
+	default:
+		sol.Outcome = OutcomeDraw
+		sol.Distance = solverCells - moves
+	}
+	return sol
+}
+
+var (
+	bottomMask = func() uint64 {
+		var m uint64
+		for c := 0; c < solverWidth; c++ {
+			m |= 1 << (c * (solverHeight + 1))
+		}
+		return m
+	}()
+	boardMask = bottomMask * (1<<solverHeight - 1)
+
+	solverOrder = engine.Standard.CenterOrder()
+)
+
+func columnMask(col int) uint64 {
+	return (1<<solverHeight - 1) << (col * (solverHeight + 1))
+}
+
+// solverPos is a position from the point of view of the player to move:
+// current holds their discs and mask all discs.
+type solverPos struct {
+	current uint64
+	mask    uint64
+	moves   int
+}
+
+func newSolverPos(pos *engine.Position, player int) (solverPos, error) {
+	if pos.Variant() != engine.Standard {
+		return solverPos{}, ErrUnsupportedVariant
+	}
+	if pos.IsWin(engine.Player1) || pos.IsWin(engine.Player2) {
+		return solverPos{}, ErrGameOver
+	}
+	return solverPos{current: pos.Mask(player), mask: pos.Occupied(), moves: pos.MoveCount()}, nil
+}
+
+// key uniquely identifies the position in 49 bits.
+func (p solverPos) key() uint64 {
+	return p.current + p.mask
+}
+
+func (p *solverPos) play(move uint64) {
+	p.current ^= p.mask
+	p.mask |= move
+	p.moves++
+}
+
+func (p solverPos) possible() uint64 {
+	return (p.mask + bottomMask) & boardMask
+}
+
+func (p solverPos) winningCells() uint64 {
+	return computeWinningCells(p.current, p.mask)
+}
+
+func (p solverPos) opponentWinningCells() uint64 {
+	return computeWinningCells(p.current^p.mask, p.mask)
+}
+
+func (p solverPos) canWinNext() bool {
+	return p.winningCells()&p.possible() != 0
+}
+
+// nonLosingMoves returns the playable cells that neither leave an opponent
+// threat unanswered nor let the opponent win by playing on top.
+func (p solverPos) nonLosingMoves() uint64 {
+	possible := p.possible()
+	opponentWin := p.opponentWinningCells()
+	if forced := possible & opponentWin; forced != 0 {
+		if forced&(forced-1) != 0 {
+			return 0 // Two threats at once cannot both be blocked
+		}
+		possible = forced
+	}
+	return possible &^ (opponentWin >> 1)
+}
+
+// moveScore ranks a move by how many winning cells it creates.
+func (p solverPos) moveScore(move uint64) int {
+	return bits.OnesCount64(computeWinningCells(p.current|move, p.mask))
+}
+
+// computeWinningCells returns the empty cells that would complete a line of
+// four for the discs in position.
+func computeWinningCells(position, mask uint64) uint64 {
+	const h = solverHeight
+
+	// Vertical
+	r := (position << 1) & (position << 2) & (position << 3)
+
+	// Horizontal, then both diagonals
+	for _, shift := range [3]uint{h + 1, h, h + 2} {
+		p := (position << shift) & (position << (2 * shift))
+		r |= p & (position << (3 * shift))
+		r |= p & (position >> shift)
+		p = (position >> shift) & (position >> (2 * shift))
+		r |= p & (position << shift)
+		r |= p & (position >> (3 * shift))
+	}
+
+	return r & (boardMask ^ mask)
+}
+
+// mirrorKey reflects a position key left to right.
+func mirrorKey(key uint64) uint64 {
+	const colBits = solverHeight + 1
+	var m uint64
+	for c := 0; c < solverWidth; c++ {
+		col := (key >> (c * colBits)) & (1<<colBits - 1)
+		m |= col << ((solverWidth - 1 - c) * colBits)
+	}
+	return m
+}
+
+// canonicalKey identifies a position and its mirror image with one key.
+func canonicalKey(key uint64) uint64 {
+	return min(key, mirrorKey(key))
+}
+
+// solverTable caches score bounds. Each slot packs the full 49-bit key and
+// an 8-bit value into one word, so slots can be read and written atomically
+// by concurrent solves without locks.
+type solverTable struct {
+	slots []uint64
+}
+
+func newSolverTable(size int) *solverTable {
+	return &solverTable{slots: make([]uint64, nextPrime(size))}
+}
+
+func (t *solverTable) put(key uint64, val int) {
+	atomic.StoreUint64(&t.slots[key%uint64(len(t.slots))], key<<8|uint64(val))
+}
+
+func (t *solverTable) get(key uint64) int {
+	e := atomic.LoadUint64(&t.slots[key%uint64(len(t.slots))])
+	if e>>8 != key {
+		return 0
+	}
+	return int(e & 0xff)
+}
+
+// nextPrime returns the smallest prime >= n. A prime table size spreads the
+// keys, which are far from uniformly distributed.
+func nextPrime(n int) int {
+	for ; ; n++ {
+		prime := n > 1
+		for d := 2; d*d <= n; d++ {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
+
+// Solver computes exact game values for 7x6 positions. It is safe for
+// concurrent use; all solves share its table and book.
+type Solver struct {
+	table *solverTable
+	book  *Book
+}
+
+// NewSolver creates a solver with a table of about tableSize entries (8
+// bytes each). The book may be nil.
+func NewSolver(tableSize int, book *Book) *Solver {
+	return &Solver{table: newSolverTable(tableSize), book: book}
+}
+
+var (
+	defaultSolver     *Solver
+	defaultSolverOnce sync.Once
+)
+
+// DefaultSolver returns a shared solver seeded with the embedded opening
+// book, creating it on first use.
+func DefaultSolver() *Solver {
+	defaultSolverOnce.Do(func() {
+		defaultSolver = NewSolver(1<<22, EmbeddedBook())
+	})
+	return defaultSolver
+}
+
+// Solve returns the exact value of pos for the player to move using the
+// default solver, without a time limit. Positions in the opening book are
+// answered instantly, but those a few discs past it can take minutes; call
+// Solver.Solve with a deadline to bound the time.
+func Solve(pos engine.Position, player int) (Solution, error) {
+	return DefaultSolver().Solve(pos, player, time.Time{})
+}
+
+// BestMove returns a column with the best exact value for the player to
+// move using the default solver, without a time limit, like Solve.
+func BestMove(pos engine.Position, player int) (int, Solution, error) {
+	return DefaultSolver().BestMove(pos, player, time.Time{})
+}
+
+// solve holds the state of a single Solve call.
+type solve struct {
+	*Solver
+	deadline time.Time
+	nodes    int64
+	aborted  bool
+}
+
+// Solve returns the exact value of pos for the player to move. A zero
+// deadline means no limit; otherwise ErrTimeout is returned once it passes.
+func (s *Solver) Solve(pos engine.Position, player int, deadline time.Time) (Solution, error) {
+	p, err := newSolverPos(&pos, player)
+	if err != nil {
+		return Solution{}, err
+	}
+	if p.moves == solverCells {
+		return newSolution(0, p.moves), nil
+	}
+
+	st := &solve{Solver: s, deadline: deadline}
+	score := st.solve(p)
+	if st.aborted {
+		return Solution{}, ErrTimeout
+	}
+	return newSolution(score, p.moves), nil
+}
+
+// BestMove returns the column with the best exact value for the player to
+// move, preferring center columns among equally good moves, and the value
+// of the position after playing it.
+func (s *Solver) BestMove(pos engine.Position, player int, deadline time.Time) (int, Solution, error) {
+	p, err := newSolverPos(&pos, player)
+	if err != nil {
+		return -1, Solution{}, err
+	}
+
+	st := &solve{Solver: s, deadline: deadline}
+	bestCol, bestScore := -1, minSolverScore-4
+	for _, c := range solverOrder {
+		move := p.possible() & columnMask(c)
+		if move == 0 {
+			continue
+		}
+
+		var score int
+		if p.winningCells()&move != 0 {
+			score = (solverCells + 1 - p.moves) / 2
+		} else {
+			child := p
+			child.play(move)
+			if child.moves == solverCells {
+				score = 0
+			} else {
+				score = -st.solve(child)
+			}
+		}
+		if st.aborted {
+			return -1, Solution{}, ErrTimeout
+		}
+
+		if score > bestScore {
+			bestCol, bestScore = c, score
+		}
+	}
+	if bestCol == -1 {
+		return -1, Solution{}, ErrGameOver
+	}
+	return bestCol, newSolution(bestScore, p.moves), nil
+}
+
+// solve narrows the score window with null-window searches until the exact
+// score is known.
+func (st *solve) solve(p solverPos) int {
+	if p.canWinNext() {
+		return (solverCells + 1 - p.moves) / 2
+	}
+
+	lo, hi := -(solverCells-p.moves)/2, (solverCells+1-p.moves)/2
+	for lo < hi && !st.aborted {
+		med := lo + (hi-lo)/2
+		// Search near zero first: most positions are close to a draw
+		if med <= 0 && lo/2 < med {
+			med = lo / 2
+		} else if med >= 0 && hi/2 > med {
+			med = hi / 2
+		}
+		if r := st.negamax(p, med, med+1); r <= med {
+			hi = r
+		} else {
+			lo = r
+		}
+	}
+	return lo
+}
+
+// negamax returns the score of p if it lies within (alpha, beta), or a bound
+// on it otherwise. The player to move must not be able to win immediately.
+func (st *solve) negamax(p solverPos, alpha, beta int) int {
+	st.nodes++
+	if st.nodes&4095 == 0 && !st.deadline.IsZero() && time.Now().After(st.deadline) {
+		st.aborted = true
+	}
+	if st.aborted {
+		return 0
+	}
+
+	next := p.nonLosingMoves()
+	if next == 0 {
+		return -(solverCells - p.moves) / 2 // The opponent wins next move
+	}
+	if p.moves >= solverCells-2 {
+		return 0 // Nobody can win with the last two stones
+	}
+
+	if lo := -(solverCells - 2 - p.moves) / 2; alpha < lo {
+		alpha = lo
+		if alpha >= beta {
+			return alpha
+		}
+	}
+	if hi := (solverCells - 1 - p.moves) / 2; beta > hi {
+		beta = hi
+		if alpha >= beta {
+			return beta
+		}
+	}
+
+	key := p.key()
+	if val := st.table.get(key); val != 0 {
+		if val > maxSolverScore-minSolverScore+1 {
+			// Lower bound
+			if lo := val + 2*minSolverScore - maxSolverScore - 2; alpha < lo {
+				alpha = lo
+				if alpha >= beta {
+					return alpha
+				}
+			}
+		} else {
+			// Upper bound
+			if hi := val + minSolverScore - 1; beta > hi {
+				beta = hi
+				if alpha >= beta {
+					return beta
+				}
+			}
+		}
+	}
+
+	if score, ok := st.book.lookup(key, p.moves); ok {
+		return score
+	}
+
+	// Try the moves that create the most threats first, center first on ties
+	var moves [solverWidth]uint64
+	var scores [solverWidth]int
+	n := 0
+	for _, c := range solverOrder {
+		move := next & columnMask(c)
+		if move == 0 {
+			continue
+		}
+		score := p.moveScore(move)
+		i := n
+		for ; i > 0 && scores[i-1] < score; i-- {
+			moves[i], scores[i] = moves[i-1], scores[i-1]
+		}
+		moves[i], scores[i] = move, score
+		n++
+	}
+
+	for _, move := range moves[:n] {
+		child := p
+		child.play(move)
+		score := -st.negamax(child, -beta, -alpha)
+		if st.aborted {
+			return 0
+		}
+		if score >= beta {
+			st.table.put(key, score+maxSolverScore-2*minSolverScore+2)
+			return score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+
+	st.table.put(key, alpha-minSolverScore+1)
+	return alpha
+}
@@ -0,0 +1,212 @@
+package ai
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"hello-go/engine"
+)
+
+// Strategy chooses moves for a bot. A Strategy may keep state between
+// moves, so each game should create its own.
+type Strategy interface {
+	// ChooseMove returns the column to play for the player to move in pos,
+	// which has at least one legal move, and optionally an evaluation.
+	ChooseMove(pos engine.Position, player int) (int, *Evaluation)
+}
+
+// Evaluation describes how a strategy rated the move it chose. Fields a
+// strategy does not compute are left zero.
+type Evaluation struct {
+	Score    int     `json:"score"`              // Positive favours the player who moved
+	Depth    int     `json:"depth,omitempty"`    // Plies searched
+	Outcome  Outcome `json:"outcome,omitempty"`  // Set when the value is proven
+	Distance int     `json:"distance,omitempty"` // Plies until a proven result
+}
+
+// Options configure a strategy created with NewStrategy. Each strategy uses
+// the options that apply to it and ignores the rest.
+type Options struct {
+	Depth       int           // Search depth in plies
+	Iterations  int           // MCTS playouts per move
+	TimeBudget  time.Duration // Time to spend per move; zero means no limit
+	SolveBudget time.Duration // Time the solver may take before searching instead
+	BlunderRate float64       // Chance of playing a random move instead
+	Rand        *rand.Rand    // Random source; nil seeds a new one from the clock
+
+	// Table caches search results; nil uses SharedTable. Strategies that
+	// are compared against each other need tables of their own, as a
+	// shallow search would otherwise use results stored by a deeper one.
+	Table *TranspositionTable
+}
+
+// table returns the transposition table the options ask for.
+func (opts Options) table() *TranspositionTable {
+	if opts.Table != nil {
+		return opts.Table
+	}
+	return SharedTable()
+}
+
+// Factory creates a strategy from options.
+type Factory func(opts Options) Strategy
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a strategy available by name. It panics if the name is
+// already registered.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("ai: Register called twice for strategy " + name)
+	}
+	registry[name] = factory
+}
+
+// Strategies returns the sorted names of the registered strategies.
+func Strategies() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStrategy creates the named strategy. A BlunderRate in the options wraps
+// it so that it sometimes plays a random move instead.
+func NewStrategy(name string, opts Options) (Strategy, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q", name)
+	}
+
+	if opts.Rand == nil {
+		opts.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	s := factory(opts)
+	if opts.BlunderRate > 0 {
+		s = &blunderer{Strategy: s, rate: opts.BlunderRate, rng: opts.Rand}
+	}
+	return s, nil
+}
+
+func init() {
+	Register("random", func(opts Options) Strategy {
+		return &Random{rng: opts.Rand}
+	})
+	Register("heuristic", func(opts Options) Strategy {
+		return &Heuristic{rng: opts.Rand}
+	})
+	Register("minimax", func(opts Options) Strategy {
+		return &Minimax{Searcher: Searcher{Depth: opts.Depth, TimeBudget: opts.TimeBudget, Table: opts.table()}}
+	})
+	Register("mcts", func(opts Options) Strategy {
+		return NewMCTS(opts.Iterations, opts.TimeBudget, opts.Rand)
+	})
+	Register("solver", func(opts Options) Strategy {
+		return &Perfect{
+			Solver:      DefaultSolver(),
+			SolveBudget: opts.SolveBudget,
+			Fallback:    Searcher{Depth: opts.Depth, TimeBudget: opts.TimeBudget, Table: opts.table()},
+		}
+	})
+}
+
+var (
+	sharedTable     *TranspositionTable
+	sharedTableOnce sync.Once
+)
+
+// SharedTable returns the transposition table shared by the strategies
+// created from the registry without a table of their own; positions from popular openings come up again
+// and again. Its 1<<20 entries take about 16MB.
+func SharedTable() *TranspositionTable {
+	sharedTableOnce.Do(func() {
+		sharedTable = NewTranspositionTable(1 << 20)
+	})
+	return sharedTable
+}
+
+// Random plays a uniformly random legal move.
+type Random struct {
+	rng *rand.Rand
+}
+
+// ChooseMove implements Strategy.
+func (r *Random) ChooseMove(pos engine.Position, player int) (int, *Evaluation) {
+	moves := pos.LegalMoves()
+	return moves[r.rng.Intn(len(moves))], nil
+}
+
+// blunderer wraps a strategy, replacing a share of its moves with random
+// ones.
+type blunderer struct {
+	Strategy
+	rate float64
+	rng  *rand.Rand
+}
+
+func (b *blunderer) ChooseMove(pos engine.Position, player int) (int, *Evaluation) {
+	if b.rng.Float64() < b.rate {
+		moves := pos.LegalMoves()
+		return moves[b.rng.Intn(len(moves))], nil
+	}
+	return b.Strategy.ChooseMove(pos, player)
+}
+
+// Minimax plays the best move found by a Searcher.
+type Minimax struct {
+	Searcher Searcher
+}
+
+// ChooseMove implements Strategy.
+func (m *Minimax) ChooseMove(pos engine.Position, player int) (int, *Evaluation) {
+	result := m.Searcher.Search(pos, player)
+	return result.Column, searchEvaluation(result)
+}
+
+// searchEvaluation converts a search result, recognising forced results.
+func searchEvaluation(r Result) *Evaluation {
+	eval := &Evaluation{Score: r.Score, Depth: r.Depth}
+	switch {
+	case r.Score > winThreshold:
+		eval.Outcome, eval.Distance = OutcomeWin, WinScore-r.Score
+	case r.Score < -winThreshold:
+		eval.Outcome, eval.Distance = OutcomeLoss, WinScore+r.Score
+	}
+	return eval
+}
+
+// Perfect plays exactly solved moves, searching instead when the solver
+// cannot finish within SolveBudget.
+type Perfect struct {
+	Solver      *Solver
+	SolveBudget time.Duration // Zero means no limit
+	Fallback    Searcher
+}
+
+// ChooseMove implements Strategy.
+func (p *Perfect) ChooseMove(pos engine.Position, player int) (int, *Evaluation) {
+	var deadline time.Time
+	if p.SolveBudget > 0 {
+		deadline = time.Now().Add(p.SolveBudget)
+	}
+	col, sol, err := p.Solver.BestMove(pos, player, deadline)
+	if err != nil {
+		result := p.Fallback.Search(pos, player)
+		return result.Column, searchEvaluation(result)
+	}
+	return col, &Evaluation{Score: sol.Score, Outcome: sol.Outcome, Distance: sol.Distance}
+}
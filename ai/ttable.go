@@ -0,0 +1,64 @@
+package ai
+
+import "sync"
+
+// Bound says how a stored score relates to the true score of a position,
+// depending on whether the search that produced it failed high or low.
+type Bound uint8
+
+const (
+	BoundExact Bound = iota + 1
+	BoundLower       // True score is at least Score
+	BoundUpper       // True score is at most Score
+)
+
+// TTEntry is a search result cached in a TranspositionTable.
+type TTEntry struct {
+	Key   uint64
+	Score int32
+	Move  int8 // Best column found, or -1
+	Depth int8 // Remaining depth the score was searched to
+	Bound Bound
+}
+
+// ttLocks is the number of mutexes guarding a table's slots; slots share
+// locks so the table stays small while games search concurrently.
+const ttLocks = 256
+
+// TranspositionTable is a fixed-size, concurrency-safe cache of search
+// results keyed by position hash. When two positions map to the same slot,
+// the one searched deeper is kept.
+type TranspositionTable struct {
+	entries []TTEntry
+	locks   [ttLocks]sync.Mutex
+}
+
+// NewTranspositionTable creates a table holding up to size entries.
+func NewTranspositionTable(size int) *TranspositionTable {
+	if size < 1 {
+		size = 1
+	}
+	return &TranspositionTable{entries: make([]TTEntry, size)}
+}
+
+// Probe returns the entry stored for key, if any.
+func (t *TranspositionTable) Probe(key uint64) (TTEntry, bool) {
+	i := key % uint64(len(t.entries))
+	mu := &t.locks[i%ttLocks]
+	mu.Lock()
+	e := t.entries[i]
+	mu.Unlock()
+	return e, e.Bound != 0 && e.Key == key
+}
+
+// Store records an entry, replacing the slot's previous entry unless that
+// was for a different position searched deeper.
+func (t *TranspositionTable) Store(e TTEntry) {
+	i := e.Key % uint64(len(t.entries))
+	mu := &t.locks[i%ttLocks]
+	mu.Lock()
+	if old := t.entries[i]; old.Bound == 0 || old.Key == e.Key || old.Depth <= e.Depth {
+		t.entries[i] = e
+	}
+	mu.Unlock()
+}
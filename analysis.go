@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"hello-go/ai"
+	"hello-go/engine"
+	"hello-go/notation"
+)
+
+const (
+	analysisDepth      = 10              // Plies searched for every position
+	analysisMoveBudget = time.Second     // Time limit per position, for the slowest middlegames
+	analysisWait       = 5 * time.Second // How long a request waits for a free slot
+)
+
+// analysisSlots limits how many games are analyzed at once, so analyses
+// can't starve the bots of CPU.
+var analysisSlots = make(chan struct{}, 2)
+
+// ErrAnalysisBusy is returned when every analysis slot stayed taken for
+// analysisWait.
+var ErrAnalysisBusy = errors.New("The analyzer is busy, try again shortly")
+
+// Analysis annotates every move of a game. Depth is the depth asked for;
+// each move records the depth its search reached in time.
+type Analysis struct {
+	Depth  int               `json:"depth"`
+	Moves  []ai.MoveAnalysis `json:"moves"`
+	Cached bool              `json:"cached"`
+}
+
+// AnalyzeRecord validates a game and annotates its moves, reusing a cached
+// analysis of the same moves if there is one. It gives up waiting for a free
+// slot when ctx is done or after analysisWait.
+func AnalyzeRecord(ctx context.Context, rec *notation.Record) (*Analysis, error) {
+	if rec.Variant != engine.Standard {
+		return nil, errors.New("Only 7x6 games can be analyzed")
+	}
+	if _, err := rec.Replay(); err != nil {
+		return nil, err
+	}
+
+	key := notation.EncodeMoves(rec.Moves, rec.Variant)
+	if cached, ok := GetCachedAnalysis(key, analysisDepth); ok {
+		cached.Cached = true
+		return cached, nil
+	}
+
+	wait := time.NewTimer(analysisWait)
+	defer wait.Stop()
+	select {
+	case analysisSlots <- struct{}{}:
+		defer func() { <-analysisSlots }()
+	case <-wait.C:
+		return nil, ErrAnalysisBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	searcher := ai.Searcher{Depth: analysisDepth, TimeBudget: analysisMoveBudget, Table: ai.SharedTable()}
+	moves, err := searcher.Analyze(rec.Variant, rec.Moves)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Analyzed %d moves in %v", len(moves), time.Since(start))
+
+	// An analysis cut short by the time limit would be served at the full
+	// depth for good, so only complete ones are cached
+	analysis := &Analysis{Depth: analysisDepth, Moves: moves}
+	if searchedFully(moves) {
+		SaveAnalysis(key, analysisDepth, analysis)
+	}
+	return analysis, nil
+}
+
+// searchedFully reports whether every move was searched to analysisDepth,
+// or to the end of the board when fewer cells were left.
+func searchedFully(moves []ai.MoveAnalysis) bool {
+	cells := engine.Standard.Cols * engine.Standard.Rows
+	for _, m := range moves {
+		if m.Depth < min(analysisDepth, cells-(m.MoveNumber-1)) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,166 +1,165 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"math/rand"
+	"os"
+	"slices"
+	"strings"
 	"time"
+
+	"hello-go/ai"
+	"hello-go/engine"
+	"hello-go/rating"
 )
 
-// Bot represents the AI opponent.
-type Bot struct{}
+// Difficulty selects how strong the bot plays.
+type Difficulty string
 
-// NewBot creates a new bot.
-func NewBot() *Bot {
-	return &Bot{}
-}
+const (
+	DifficultyEasy    Difficulty = "easy"    // Heuristic with frequent random blunders
+	DifficultyMedium  Difficulty = "medium"  // Win/block/center heuristic
+	DifficultyHard    Difficulty = "hard"    // Depth-limited search
+	DifficultyPerfect Difficulty = "perfect" // Exact solver with the opening book
 
-// MakeMove triggers the bot to find and make a move.
-func (b *Bot) MakeMove(g *Game) {
-	// Add a small delay to make it feel more human
-	time.Sleep(time.Duration(500+rand.Intn(1000)) * time.Millisecond)
+	defaultDifficulty = DifficultyHard
+)
 
-	g.mutex.RLock() // Use RLock for reading the board state
-	// Create a copy of the board for the bot to analyze
-	boardCopy := g.Board
-	g.mutex.RUnlock()
+// ParseDifficulty validates a difficulty sent by a client. An empty string
+// selects the default.
+func ParseDifficulty(s string) (Difficulty, error) {
+	switch d := Difficulty(s); d {
+	case "":
+		return defaultDifficulty, nil
+	case DifficultyEasy, DifficultyMedium, DifficultyHard, DifficultyPerfect:
+		return d, nil
+	}
+	return "", fmt.Errorf("Unknown difficulty %q. Choose easy, medium, hard or perfect.", s)
+}
 
-	// Find the best move
-	col := b.findBestMove(boardCopy, Player2, Player1)
+// botProfile is the default strategy for a difficulty and the options any
+// strategy plays that difficulty with.
+type botProfile struct {
+	strategy string
+	options  ai.Options
+}
 
-	// Make the move by calling the game's handler
-	// We pass 'nil' as the player to signify it's a bot move
-	g.HandleMove(nil, col)
+var botProfiles = map[Difficulty]botProfile{
+	DifficultyEasy:   {"heuristic", ai.Options{BlunderRate: 0.4, Iterations: 500}},
+	DifficultyMedium: {"heuristic", ai.Options{Iterations: 5000}},
+	DifficultyHard:   {"minimax", ai.Options{Depth: 10, TimeBudget: 2 * time.Second}},
+	// The book answers positions with up to 5 discs instantly. Past it the
+	// solver rarely finishes within SolveBudget until well into the
+	// middlegame, so those early moves fall back to a deep search and
+	// aren't guaranteed perfect.
+	DifficultyPerfect: {"solver", ai.Options{
+		SolveBudget: 3 * time.Second,
+		Depth:       engine.Standard.Cols * engine.Standard.Rows,
+		TimeBudget:  2 * time.Second,
+	}},
 }
 
-// findBestMove is the core bot logic.
-func (b *Bot) findBestMove(board [Rows][Cols]int, botPlayer, humanPlayer int) int {
+// botRatings are the fixed ratings players are measured against in bot
+// games. The bot isn't rated itself, so these don't drift as players learn
+// its weaknesses.
+var botRatings = map[Difficulty]rating.Rating{
+	DifficultyEasy:    {Rating: 1000, Deviation: 50, Volatility: rating.DefaultVolatility},
+	DifficultyMedium:  {Rating: 1300, Deviation: 50, Volatility: rating.DefaultVolatility},
+	DifficultyHard:    {Rating: 1700, Deviation: 50, Volatility: rating.DefaultVolatility},
+	DifficultyPerfect: {Rating: 2300, Deviation: 50, Volatility: rating.DefaultVolatility},
+}
 
-	// 1. Check for immediate winning moves for the bot
-	for c := 0; c < Cols; c++ {
-		if !isValidMove(board, c) {
+// pickBotStrategy returns the strategy name for a new bot game. Setting
+// BOT_STRATEGY_<DIFFICULTY> (e.g. BOT_STRATEGY_HARD=minimax,random) to a
+// comma-separated list splits games evenly between those strategies for
+// A/B tests.
+func pickBotStrategy(difficulty Difficulty) string {
+	env := "BOT_STRATEGY_" + strings.ToUpper(string(difficulty))
+	var names []string
+	for _, name := range strings.Split(os.Getenv(env), ",") {
+		if name = strings.TrimSpace(name); name == "" {
 			continue
 		}
-		r := getNextOpenRow(board, c)
-		board[r][c] = botPlayer // Try move
-		if checkWin(board, r, c, botPlayer) {
-			log.Println("Bot: Found winning move at col", c)
-			return c
-		}
-		board[r][c] = Empty // Undo move
-	}
-
-	// 2. Check for immediate winning moves for the human (and block them)
-	for c := 0; c < Cols; c++ {
-		if !isValidMove(board, c) {
+		if !slices.Contains(ai.Strategies(), name) {
+			log.Printf("Ignoring unknown strategy %q in %s", name, env)
 			continue
 		}
-		r := getNextOpenRow(board, c)
-		board[r][c] = humanPlayer // Try human move
-		if checkWin(board, r, c, humanPlayer) {
-			log.Println("Bot: Found blocking move at col", c)
-			return c
-		}
-		board[r][c] = Empty // Undo move
+		names = append(names, name)
 	}
-
-	// 3. Simple heuristic: try to play in the center
-	centerCols := []int{3, 2, 4, 1, 5, 0, 6}
-	for _, c := range centerCols {
-		if isValidMove(board, c) {
-			// Basic check: don't set up the opponent for a win
-			r := getNextOpenRow(board, c)
-			if r > 0 { // Don't check if we're at the very top
-				board[r-1][c] = humanPlayer
-				if checkWin(board, r-1, c, humanPlayer) {
-					board[r-1][c] = Empty // Undo check
-					continue              // This move would let the human win, skip it
-				}
-				board[r-1][c] = Empty // Undo check
-			}
-			log.Println("Bot: Playing preferred center col", c)
-			return c
-		}
-	}
-
-	// 4. Fallback: play any valid random move
-	for {
-		c := rand.Intn(Cols)
-		if isValidMove(board, c) {
-			log.Println("Bot: Playing random fallback col", c)
-			return c
-		}
+	if len(names) == 0 {
+		return botProfiles[difficulty].strategy
 	}
+	return names[rand.Intn(len(names))]
 }
 
-// --- Bot Utility Functions ---
-// These are static helpers for the bot to analyze hypothetical boards.
-
-func isValidMove(board [Rows][Cols]int, col int) bool {
-	return board[0][col] == Empty
+// BotOptions configure the bot of a new bot game.
+type BotOptions struct {
+	Strategy string       // Name in the ai registry; empty uses the difficulty's default
+	Thinking ThinkingTime // Delay before each move; nil plays instantly
+	Rand     *rand.Rand   // Random source for the strategy and delays; nil seeds one from the clock
 }
 
-func getNextOpenRow(board [Rows][Cols]int, col int) int {
-	for r := Rows - 1; r >= 0; r-- {
-		if board[r][col] == Empty {
-			return r
-		}
-	}
-	return -1
+// Bot represents the AI opponent.
+type Bot struct {
+	Difficulty   Difficulty
+	StrategyName string
+	Strategy     ai.Strategy
+	Thinking     ThinkingTime
+	rng          *rand.Rand // Only used from the goroutine making the bot's move
 }
 
-// checkWin is a static version of the game's win check for the bot.
-func checkWin(board [Rows][Cols]int, lastRow, lastCol, playerNum int) bool {
-	// Check horizontal
-	count := 0
-	for c := 0; c < Cols; c++ {
-		if board[lastRow][c] == playerNum {
-			count++
-			if count >= 4 {
-				return true
-			}
-		} else {
-			count = 0
-		}
+// NewBot creates a bot playing the chosen strategy with the difficulty's
+// options. An unknown strategy falls back to the difficulty's default.
+func NewBot(difficulty Difficulty, opts BotOptions) *Bot {
+	profile := botProfiles[difficulty]
+	rng := opts.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
 	}
+	options := profile.options
+	options.Rand = rng
 
-	// Check vertical
-	count = 0
-	for r := 0; r < Rows; r++ {
-		if board[r][lastCol] == playerNum {
-			count++
-			if count >= 4 {
-				return true
-			}
-		} else {
-			count = 0
-		}
+	name := opts.Strategy
+	if name == "" {
+		name = profile.strategy
+	}
+	strategy, err := ai.NewStrategy(name, options)
+	if err != nil {
+		log.Printf("Bot: %v, using %s", err, profile.strategy)
+		name = profile.strategy
+		strategy, _ = ai.NewStrategy(name, options)
 	}
 
-	// Check diagonals (top-left to bottom-right)
-	count = 0
-	for r, c := lastRow-min(lastRow, lastCol), lastCol-min(lastRow, lastCol); r < Rows && c < Cols; r, c = r+1, c+1 {
-		if board[r][c] == playerNum {
-			count++
-			if count >= 4 {
-				return true
-			}
-		} else {
-			count = 0
-		}
+	thinking := opts.Thinking
+	if thinking == nil {
+		thinking = NoThinking{}
 	}
+	return &Bot{Difficulty: difficulty, StrategyName: name, Strategy: strategy, Thinking: thinking, rng: rng}
+}
 
-	// Check diagonals (bottom-left to top-right)
-	count = 0
-	for r, c := lastRow+min(Rows-1-lastRow, lastCol), lastCol-min(Rows-1-lastRow, lastCol); r >= 0 && c < Cols; r, c = r-1, c+1 {
-		if board[r][c] == playerNum {
-			count++
-			if count >= 4 {
-				return true
-			}
-		} else {
-			count = 0
-		}
+// MakeMove triggers the bot to find and make a move.
+func (b *Bot) MakeMove(g *Game) {
+	g.mutex.RLock() // Use RLock for reading the board state
+	// Take a bitboard copy of the board for the bot to analyze
+	pos, _ := g.Board.Position()
+	me := g.BotPlayer
+	g.mutex.RUnlock()
+
+	// Find the best move
+	start := time.Now()
+	col, eval := b.Strategy.ChooseMove(pos, me)
+	searched := time.Since(start)
+	if eval != nil {
+		log.Printf("Bot (%s): Playing col %d in %v (score %d, depth %d, outcome %q)", b.StrategyName, col, searched, eval.Score, eval.Depth, eval.Outcome)
+	} else {
+		log.Printf("Bot (%s): Playing col %d in %v", b.StrategyName, col, searched)
 	}
 
-	return false
+	// Wait a little longer to make it feel more human
+	time.Sleep(b.Thinking.Delay(searched, b.rng))
+
+	// Make the move by calling the game's handler
+	// We pass 'nil' as the player to signify it's a bot move
+	g.HandleMove(nil, col)
 }
@@ -0,0 +1,25 @@
+package main
+
+import "hello-go/notation"
+
+// Notation converts a finished game to c4n notation.
+func (r *GameRecord) Notation() *notation.Record {
+	rec := notation.NewRecord()
+	rec.Player1 = r.Player1
+	rec.Player2 = r.Player2
+	rec.Date = r.StartTime
+	rec.Result = notation.ResultFor(r.WinnerNumber())
+	rec.SetTag("GameId", r.ID)
+	if r.IsBot {
+		rec.SetTag("Difficulty", string(r.Difficulty))
+	}
+	if r.Reason != "" {
+		rec.SetTag("Termination", r.Reason)
+	}
+	discs := discMoves(r.Moves)
+	rec.Moves = make([]int, len(discs))
+	for i, move := range discs {
+		rec.Moves[i] = move.Column
+	}
+	return rec
+}
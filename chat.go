@@ -0,0 +1,172 @@
+package main
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxChatLength = 200              // Characters per message
+	chatBurst     = 5                // Messages allowed per chatWindow
+	chatWindow    = 10 * time.Second // Window for chatBurst
+)
+
+// defaultBlockedWords are masked when CHAT_BLOCKED_WORDS isn't set.
+var defaultBlockedWords = []string{"fuck", "shit", "bitch", "bastard", "asshole", "cunt", "dick", "damn", "crap"}
+
+// rateLimiter allows up to limit events in any window of the given length.
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+	times  []time.Time // Recent events, oldest first
+	mutex  sync.Mutex
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window}
+}
+
+// Allow records an event at now and reports whether it is within the limit.
+// Rejected events don't count towards the limit.
+func (r *rateLimiter) Allow(now time.Time) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cutoff := now.Add(-r.window)
+	for len(r.times) > 0 && !r.times[0].After(cutoff) {
+		r.times = r.times[1:]
+	}
+	if len(r.times) >= r.limit {
+		return false
+	}
+	r.times = append(r.times, now)
+	return true
+}
+
+// WordFilter masks blocked words in chat messages. A blocked word also
+// matches longer words starting with it, so "damn" catches "damned".
+type WordFilter struct {
+	pattern *regexp.Regexp
+}
+
+// NewWordFilter creates a filter for the given words, ignoring case. It
+// returns nil, which filters nothing, if there are no words.
+func NewWordFilter(words []string) *WordFilter {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	return &WordFilter{pattern: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\w*`)}
+}
+
+// Clean replaces every blocked word with asterisks and reports whether any
+// were found.
+func (f *WordFilter) Clean(text string) (string, bool) {
+	if f == nil {
+		return text, false
+	}
+	filtered := false
+	cleaned := f.pattern.ReplaceAllStringFunc(text, func(word string) string {
+		filtered = true
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	})
+	return cleaned, filtered
+}
+
+// chatFilterFromEnv reads the blocked words from CHAT_BLOCKED_WORDS, a
+// comma-separated list, or "none" to turn the filter off.
+func chatFilterFromEnv() *WordFilter {
+	s := os.Getenv("CHAT_BLOCKED_WORDS")
+	switch s {
+	case "":
+		return NewWordFilter(defaultBlockedWords)
+	case "none":
+		return nil
+	}
+	return NewWordFilter(strings.Split(s, ","))
+}
+
+// chatSpectatorsFromEnv reports whether spectators see the players' chat,
+// which CHAT_SPECTATORS=false turns off.
+func chatSpectatorsFromEnv() bool {
+	return os.Getenv("CHAT_SPECTATORS") != "false"
+}
+
+// ChatMessage is a chat line as relayed to the players and spectators.
+type ChatMessage struct {
+	GameID string    `json:"gameId"`
+	From   string    `json:"from"`
+	Player int       `json:"player"`
+	Text   string    `json:"text"`
+	Time   time.Time `json:"time"`
+}
+
+// HandleChat relays a chat message from one of the players to everyone in
+// the game who hasn't muted chat. The sender always gets their own message
+// back, as the others see it. Only private room games have free chat;
+// strangers from the quick match queue have emotes instead.
+func (g *Game) HandleChat(player *Player, text string) {
+	if !g.Private {
+		player.SendError("Chat is only available in private room games.")
+		return
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		player.SendError("Message cannot be empty.")
+		return
+	}
+	if utf8.RuneCountInString(text) > maxChatLength {
+		player.SendError("Message is too long.")
+		return
+	}
+
+	now := time.Now()
+	if !player.chatLimit.Allow(now) {
+		player.SendError("You're sending messages too fast.")
+		return
+	}
+
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	cleaned, filtered := g.manager.ChatFilter.Clean(text)
+	msg := ChatMessage{GameID: g.ID, From: player.Username, Player: g.numberOf(player), Text: cleaned, Time: now}
+
+	for _, p := range g.humans() { // Either player is nil against the bot
+		if p == player || !p.ChatMuted() {
+			p.SendMessage("chat", msg)
+		}
+	}
+	if g.manager.ChatSpectators {
+		g.forEachSpectator(func(spectator *Player) {
+			if !spectator.ChatMuted() {
+				spectator.SendMessage("chat", msg)
+			}
+		})
+	}
+
+	if filtered {
+		log.Printf("Filtered chat message from %s in game %s", player.Username, g.ID)
+	}
+
+	// The original text is kept for moderation review
+	go ProduceEvent("chat_message", map[string]interface{}{
+		"gameId":   g.ID,
+		"player":   player.Username,
+		"text":     text,
+		"sent":     cleaned,
+		"filtered": filtered,
+		"gameTime": now.Unix(),
+	})
+}
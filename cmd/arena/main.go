@@ -0,0 +1,291 @@
+// Command arena measures the relative strength of two bot strategies by
+// playing them against each other headlessly. Each opening is played twice
+// with the colours swapped, so neither side profits from moving first.
+//
+//	go run ./cmd/arena -a minimax:depth=10 -b mcts:iterations=5000 -games 200 -openings 2
+//
+// A strategy is given as name[:key=value,...] with keys depth, iterations,
+// time, solve and blunder; keys left out take the -depth, -iterations,
+// -time, -solve and -blunder defaults.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"hello-go/ai"
+	"hello-go/engine"
+)
+
+// maxElo caps the reported Elo difference, which is infinite for a clean
+// sweep.
+const maxElo = 1200
+
+// player is one side of the match.
+type player struct {
+	spec     string
+	strategy ai.Strategy
+	moves    int
+	thinking time.Duration // Total time spent choosing moves
+}
+
+// Report summarises a match from the point of view of strategy A.
+type Report struct {
+	A            string  `json:"a"`
+	B            string  `json:"b"`
+	Variant      string  `json:"variant"`
+	Openings     int     `json:"openingMoves"`
+	Games        int     `json:"games"`
+	Wins         int     `json:"wins"`
+	Draws        int     `json:"draws"`
+	Losses       int     `json:"losses"`
+	Score        float64 `json:"score"` // (wins + draws/2) / games
+	AvgLength    float64 `json:"avgLength"`
+	AvgMoveTimeA float64 `json:"avgMoveTimeMsA"`
+	AvgMoveTimeB float64 `json:"avgMoveTimeMsB"`
+	Elo          float64 `json:"eloDiff"`
+	EloLow       float64 `json:"eloLow"` // 95% confidence interval
+	EloHigh      float64 `json:"eloHigh"`
+	DurationSecs float64 `json:"durationSecs"`
+	totalMoves   int
+}
+
+func main() {
+	specA := flag.String("a", "minimax", "first strategy, as name[:key=value,...]")
+	specB := flag.String("b", "heuristic", "second strategy, as name[:key=value,...]")
+	games := flag.Int("games", 100, "number of games; rounded up to an even number")
+	openings := flag.Int("openings", 0, "random moves played before the strategies take over")
+	variantName := flag.String("variant", engine.Standard.String(), "board variant as COLSxROWS/CONNECT")
+	seed := flag.Int64("seed", 1, "random seed for openings and strategies")
+	depth := flag.Int("depth", 10, "default search depth")
+	iterations := flag.Int("iterations", 5000, "default MCTS playouts per move")
+	budget := flag.Duration("time", 0, "default time budget per move (0 for no limit)")
+	solveBudget := flag.Duration("solve", 3*time.Second, "default solver time budget per move")
+	blunder := flag.Float64("blunder", 0, "default chance of a random move")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+	if *games < 1 {
+		log.Fatalf("-games must be at least 1, got %d", *games)
+	}
+
+	variant, err := engine.ParseVariant(*variantName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !engine.FitsBitboard(variant) {
+		log.Fatalf("variant %s is too large for the bots", variant)
+	}
+
+	defaults := ai.Options{
+		Depth:       *depth,
+		Iterations:  *iterations,
+		TimeBudget:  *budget,
+		SolveBudget: *solveBudget,
+		BlunderRate: *blunder,
+	}
+	rng := rand.New(rand.NewSource(*seed))
+	a, err := newPlayer(*specA, defaults, rand.New(rand.NewSource(rng.Int63())))
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := newPlayer(*specB, defaults, rand.New(rand.NewSource(rng.Int63())))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report := &Report{A: a.spec, B: b.spec, Variant: variant.String(), Openings: *openings}
+	start := time.Now()
+	for report.Games < *games {
+		opening := randomOpening(variant, *openings, rng)
+		// Play the opening once with each strategy moving first
+		for _, first := range []*player{a, b} {
+			second := b
+			if first == b {
+				second = a
+			}
+			winner, moves := playGame(variant, opening, first, second)
+			report.record(winner, a, moves)
+		}
+		if report.Games%20 == 0 {
+			log.Printf("%d/%d games: +%d =%d -%d", report.Games, *games, report.Wins, report.Draws, report.Losses)
+		}
+	}
+	report.DurationSecs = time.Since(start).Seconds()
+	report.finish(a, b)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	report.print()
+}
+
+// newPlayer creates a strategy from a name[:key=value,...] spec.
+func newPlayer(spec string, opts ai.Options, rng *rand.Rand) (*player, error) {
+	name, params, _ := strings.Cut(spec, ":")
+	if params != "" {
+		for _, param := range strings.Split(params, ",") {
+			key, value, ok := strings.Cut(param, "=")
+			if !ok {
+				return nil, fmt.Errorf("strategy %q: expected key=value, got %q", spec, param)
+			}
+			var err error
+			switch key {
+			case "depth":
+				opts.Depth, err = strconv.Atoi(value)
+			case "iterations":
+				opts.Iterations, err = strconv.Atoi(value)
+			case "time":
+				opts.TimeBudget, err = time.ParseDuration(value)
+			case "solve":
+				opts.SolveBudget, err = time.ParseDuration(value)
+			case "blunder":
+				opts.BlunderRate, err = strconv.ParseFloat(value, 64)
+			default:
+				return nil, fmt.Errorf("strategy %q: unknown option %q", spec, key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("strategy %q: %v", spec, err)
+			}
+		}
+	}
+
+	// A table of its own keeps one side from reading the other's results
+	opts.Rand = rng
+	opts.Table = ai.NewTranspositionTable(1 << 20)
+	strategy, err := ai.NewStrategy(name, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%v (available: %s)", err, strings.Join(ai.Strategies(), ", "))
+	}
+	return &player{spec: spec, strategy: strategy}, nil
+}
+
+// randomOpening returns up to n random columns that don't end the game.
+func randomOpening(v engine.Variant, n int, rng *rand.Rand) []int {
+	pos, _ := engine.NewPosition(v)
+	player := engine.Player1
+	var opening []int
+	for len(opening) < n {
+		var moves []int
+		for _, col := range pos.LegalMoves() {
+			if !pos.IsWinningMove(col, player) {
+				moves = append(moves, col)
+			}
+		}
+		if len(moves) == 0 || pos.MoveCount()+1 == v.Cols*v.Rows {
+			break
+		}
+		col := moves[rng.Intn(len(moves))]
+		pos.Play(col, player)
+		opening = append(opening, col)
+		player = 3 - player
+	}
+	return opening
+}
+
+// playGame plays the opening and then lets the strategies take turns. It
+// returns the winning player, nil for a draw, and the number of moves.
+func playGame(v engine.Variant, opening []int, first, second *player) (*player, int) {
+	board, _ := engine.NewBoard(v)
+	for i, col := range opening {
+		board.Drop(col, i%2+1)
+	}
+
+	sides := [2]*player{first, second}
+	for {
+		turn := board.MoveCount() % 2
+		current := sides[turn]
+		pos, _ := board.Position()
+
+		start := time.Now()
+		col, _ := current.strategy.ChooseMove(pos, turn+1)
+		current.thinking += time.Since(start)
+		current.moves++
+
+		row, err := board.Drop(col, turn+1)
+		if err != nil {
+			// An illegal move forfeits the game
+			log.Printf("%s played column %d: %v", current.spec, col, err)
+			return sides[1-turn], board.MoveCount()
+		}
+		if over, winner := board.Outcome(row, col); over {
+			if winner == engine.Empty {
+				return nil, board.MoveCount()
+			}
+			return sides[winner-1], board.MoveCount()
+		}
+	}
+}
+
+// record counts a finished game for strategy a.
+func (r *Report) record(winner, a *player, moves int) {
+	r.Games++
+	r.totalMoves += moves
+	switch winner {
+	case nil:
+		r.Draws++
+	case a:
+		r.Wins++
+	default:
+		r.Losses++
+	}
+}
+
+// finish computes the averages and the Elo estimate. The confidence interval
+// comes from the standard error of the per-game score.
+func (r *Report) finish(a, b *player) {
+	n := float64(r.Games)
+	r.Score = (float64(r.Wins) + float64(r.Draws)/2) / n
+	r.AvgLength = float64(r.totalMoves) / n
+	r.AvgMoveTimeA = a.avgMoveTime()
+	r.AvgMoveTimeB = b.avgMoveTime()
+
+	variance := (float64(r.Wins)*math.Pow(1-r.Score, 2) +
+		float64(r.Draws)*math.Pow(0.5-r.Score, 2) +
+		float64(r.Losses)*math.Pow(r.Score, 2)) / n
+	margin := 1.96 * math.Sqrt(variance/n)
+	r.Elo = elo(r.Score)
+	r.EloLow = elo(r.Score - margin)
+	r.EloHigh = elo(r.Score + margin)
+}
+
+// avgMoveTime returns the average time the player took per move, in
+// milliseconds.
+func (p *player) avgMoveTime() float64 {
+	if p.moves == 0 {
+		return 0
+	}
+	return float64(p.thinking.Microseconds()) / 1000 / float64(p.moves)
+}
+
+// elo converts an expected score into an Elo difference, capped at maxElo.
+func elo(score float64) float64 {
+	if score <= 0 {
+		return -maxElo
+	}
+	if score >= 1 {
+		return maxElo
+	}
+	return math.Max(-maxElo, math.Min(maxElo, -400*math.Log10(1/score-1)))
+}
+
+func (r *Report) print() {
+	fmt.Printf("%s vs %s: %d games on %s, %d random opening moves\n", r.A, r.B, r.Games, r.Variant, r.Openings)
+	fmt.Printf("  %s: %d wins, %d draws, %d losses (score %.1f%%)\n", r.A, r.Wins, r.Draws, r.Losses, 100*r.Score)
+	fmt.Printf("  Average game length: %.1f moves\n", r.AvgLength)
+	fmt.Printf("  Average move time: %.1fms (%s), %.1fms (%s)\n", r.AvgMoveTimeA, r.A, r.AvgMoveTimeB, r.B)
+	fmt.Printf("  Elo difference: %+.0f (95%% CI %+.0f to %+.0f)\n", r.Elo, r.EloLow, r.EloHigh)
+	fmt.Printf("  Took %.1fs\n", r.DurationSecs)
+}
@@ -0,0 +1,130 @@
+// Command c4n validates games in c4n notation and prints their positions.
+//
+//	go run ./cmd/c4n game.c4n          # headers and final position
+//	go run ./cmd/c4n -all game.c4n     # the position after every move
+//	go run ./cmd/c4n -move 10 game.c4n # the position after move 10
+//	echo 4453 | go run ./cmd/c4n -     # read from standard input
+//
+// It exits with status 1 if any game is invalid.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"hello-go/engine"
+	"hello-go/notation"
+)
+
+func main() {
+	all := flag.Bool("all", false, "print the position after every move")
+	move := flag.Int("move", -1, "print the position after this move (0 for the empty board)")
+	quiet := flag.Bool("q", false, "only validate; print nothing for valid games")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: c4n [flags] file.c4n... (- for standard input)\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, name := range flag.Args() {
+		if err := check(name, *all, *move, *quiet); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// check validates one file and prints the requested positions.
+func check(name string, all bool, move int, quiet bool) error {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	rec, err := notation.Parse(r)
+	if err != nil {
+		return err
+	}
+	positions, err := rec.Replay()
+	if err != nil {
+		return err
+	}
+	if quiet {
+		return nil
+	}
+	if move > len(rec.Moves) {
+		return fmt.Errorf("the game has only %d moves", len(rec.Moves))
+	}
+
+	fmt.Printf("%s: %s vs %s, %s, %d moves, result %s\n", name, orUnknown(rec.Player1), orUnknown(rec.Player2),
+		rec.Variant, len(rec.Moves), rec.Result)
+	switch {
+	case all:
+		for i, b := range positions {
+			printPosition(rec, i, b)
+		}
+	case move >= 0:
+		printPosition(rec, move, positions[move])
+	default:
+		printPosition(rec, len(rec.Moves), positions[len(positions)-1])
+	}
+	return nil
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "?"
+	}
+	return s
+}
+
+// printPosition prints the board after the given number of moves, X for
+// player 1 and O for player 2.
+func printPosition(rec *notation.Record, moves int, b *engine.Board) {
+	v := b.Variant()
+	if moves == 0 {
+		fmt.Println("\nStart:")
+	} else {
+		fmt.Printf("\nAfter move %d (column %d):\n", moves, rec.Moves[moves-1]+1)
+	}
+
+	var sb strings.Builder
+	for row := 0; row < v.Rows; row++ {
+		sb.WriteString("|")
+		for col := 0; col < v.Cols; col++ {
+			switch b.Cell(row, col) {
+			case engine.Player1:
+				sb.WriteString(" X")
+			case engine.Player2:
+				sb.WriteString(" O")
+			default:
+				sb.WriteString(" .")
+			}
+		}
+		sb.WriteString(" |\n")
+	}
+	sb.WriteString("+")
+	sb.WriteString(strings.Repeat("--", v.Cols))
+	sb.WriteString("-+\n ")
+	for col := 1; col <= v.Cols; col++ {
+		fmt.Fprintf(&sb, "%2d", col%10)
+	}
+	sb.WriteString("\n")
+	fmt.Print(sb.String())
+}
@@ -0,0 +1,101 @@
+// Command genbook generates the solver's opening book by solving every 7x6
+// position with up to -depth discs. Only the deepest positions are solved
+// from scratch; the shallower ones find their children in the book, so they
+// take no time. Run it through go generate in ./ai.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"hello-go/ai"
+	"hello-go/engine"
+)
+
+// node is a position waiting to be solved, with the player to move.
+type node struct {
+	pos    engine.Position
+	player int
+}
+
+func main() {
+	depth := flag.Int("depth", 5, "solve all positions with up to this many discs")
+	out := flag.String("out", "book.bin", "output file")
+	tableSize := flag.Int("table", 1<<23, "solver transposition table entries")
+	flag.Parse()
+
+	levels := enumerate(*depth)
+
+	// Solve the deepest positions first: every shallower position then finds
+	// its children in the book being built.
+	book := ai.NewBook(*depth)
+	solver := ai.NewSolver(*tableSize, book)
+	start := time.Now()
+	total := 0
+	for moves := *depth; moves >= 0; moves-- {
+		levelStart := time.Now()
+		for i, n := range levels[moves] {
+			if i > 0 && i%100 == 0 {
+				log.Printf("  %d/%d positions with %d moves (%v)", i, len(levels[moves]), moves, time.Since(levelStart).Round(time.Second))
+			}
+
+			sol, err := solver.Solve(n.pos, n.player, time.Time{})
+			if err != nil {
+				log.Fatalf("solve position with %d moves: %v", moves, err)
+			}
+			if err := book.Add(n.pos, n.player, sol); err != nil {
+				log.Fatalf("add position with %d moves: %v", moves, err)
+			}
+		}
+		total += len(levels[moves])
+		log.Printf("Solved %d positions with %d moves in %v", len(levels[moves]), moves, time.Since(levelStart).Round(time.Millisecond))
+	}
+
+	// Every position must be in the book, or the solver would have to search
+	// an opening position from scratch at run time
+	if book.Len() != total {
+		log.Fatalf("book holds %d positions, want %d", book.Len(), total)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("create book: %v", err)
+	}
+	if _, err := book.WriteTo(f); err != nil {
+		log.Fatalf("write book: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("close book: %v", err)
+	}
+	log.Printf("Wrote %d positions to %s in %v", book.Len(), *out, time.Since(start).Round(time.Second))
+}
+
+// enumerate returns the unfinished positions reachable with each number of
+// moves, one per mirror-image pair.
+func enumerate(depth int) [][]node {
+	levels := make([][]node, depth+1)
+	levels[0] = []node{{pos: *engine.NewStandardPosition(), player: engine.Player1}}
+	for moves := 1; moves <= depth; moves++ {
+		seen := make(map[uint64]bool)
+		for _, parent := range levels[moves-1] {
+			for _, col := range parent.pos.LegalMoves() {
+				if parent.pos.IsWinningMove(col, parent.player) {
+					continue // Finished games need no book entry
+				}
+				child := node{pos: parent.pos, player: 3 - parent.player}
+				child.pos.Play(col, parent.player)
+				key, err := ai.BookKey(child.pos, child.player)
+				if err != nil {
+					log.Fatalf("book key: %v", err)
+				}
+				if !seen[key] {
+					seen[key] = true
+					levels[moves] = append(levels[moves], child)
+				}
+			}
+		}
+	}
+	return levels
+}
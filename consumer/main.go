@@ -92,6 +92,18 @@ func handleEvent(data []byte) {
 		// Update analytics metrics
 		processGameEndAnalytics(event.Data)
 
+	case "match_ended":
+		log.Printf("Match Ended: %+v", event.Data)
+
+	case "game_action":
+		log.Printf("Game Action: %v %v in game %v", event.Data["player"], event.Data["action"], event.Data["gameId"])
+
+	case "chat_message":
+		// Filtered messages are flagged for moderation review
+		if filtered, _ := event.Data["filtered"].(bool); filtered {
+			log.Printf("Moderation: filtered chat from %v in game %v: %q", event.Data["player"], event.Data["gameId"], event.Data["text"])
+		}
+
 	default:
 		log.Printf("Unknown event type: %s", event.Type)
 	}
@@ -3,15 +3,25 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"sort"
 	"time"
 
+	"hello-go/rating"
+
 	_ "github.com/lib/pq"
 )
 
 var db *sql.DB
 
+var (
+	ErrNoDatabase   = errors.New("Database unavailable")
+	ErrGameNotFound = errors.New("Game not found")
+)
+
 func InitDB() {
 	connStr := os.Getenv("DATABASE_URL")
 	if connStr == "" {
@@ -49,6 +59,8 @@ func createTables() {
 			winner VARCHAR(255),
 			board JSONB,
 			is_bot BOOLEAN DEFAULT FALSE,
+			difficulty VARCHAR(16),
+			bot_strategy VARCHAR(32),
 			start_time TIMESTAMP NOT NULL,
 			end_time TIMESTAMP NOT NULL,
 			duration FLOAT,
@@ -63,7 +75,70 @@ func createTables() {
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`CREATE TABLE IF NOT EXISTS game_moves (
+			game_id VARCHAR(255) NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+			move_number INT NOT NULL,
+			column_index INT NOT NULL,
+			row_index INT NOT NULL,
+			player INT NOT NULL,
+			played_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (game_id, move_number)
+		)`,
+		`CREATE TABLE IF NOT EXISTS move_analyses (
+			moves VARCHAR(64) NOT NULL,
+			depth INT NOT NULL,
+			analysis JSONB NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (moves, depth)
+		)`,
+		`CREATE TABLE IF NOT EXISTS matches (
+			id VARCHAR(255) PRIMARY KEY,
+			player1 VARCHAR(255) NOT NULL,
+			player2 VARCHAR(255) NOT NULL,
+			best_of INT NOT NULL,
+			player1_wins INT NOT NULL,
+			player2_wins INT NOT NULL,
+			draws INT NOT NULL,
+			winner VARCHAR(255) NOT NULL,
+			games_played INT NOT NULL,
+			start_time TIMESTAMP NOT NULL,
+			end_time TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS rating_history (
+			game_id VARCHAR(255) NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+			username VARCHAR(255) NOT NULL,
+			pool VARCHAR(8) NOT NULL,
+			rating_before FLOAT NOT NULL,
+			rating_after FLOAT NOT NULL,
+			deviation_before FLOAT NOT NULL,
+			deviation_after FLOAT NOT NULL,
+			volatility_after FLOAT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (game_id, username)
+		)`,
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS difficulty VARCHAR(16)`,
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS bot_strategy VARCHAR(32)`,
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS bot_player SMALLINT`, // NULL for player games and older bot games, where it was 2
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS match_id VARCHAR(255)`,
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS match_game INT`,
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS result_reason VARCHAR(16)`, // NULL for games saved before reasons were recorded
+		`ALTER TABLE game_moves ADD COLUMN IF NOT EXISTS action VARCHAR(16)`,   // NULL for discs; actions have column and row -1
+		`ALTER TABLE players ADD COLUMN IF NOT EXISTS matches_played INT DEFAULT 0`,
+		`ALTER TABLE players ADD COLUMN IF NOT EXISTS matches_won INT DEFAULT 0`,
+		`ALTER TABLE players ADD COLUMN IF NOT EXISTS matches_lost INT DEFAULT 0`,
+		`ALTER TABLE players ADD COLUMN IF NOT EXISTS rating FLOAT DEFAULT 1500`,
+		`ALTER TABLE players ADD COLUMN IF NOT EXISTS rating_deviation FLOAT DEFAULT 350`,
+		`ALTER TABLE players ADD COLUMN IF NOT EXISTS rating_volatility FLOAT DEFAULT 0.06`,
+		`ALTER TABLE players ADD COLUMN IF NOT EXISTS rated_games INT DEFAULT 0`,
+		`ALTER TABLE players ADD COLUMN IF NOT EXISTS bot_rating FLOAT DEFAULT 1500`,
+		`ALTER TABLE players ADD COLUMN IF NOT EXISTS bot_rating_deviation FLOAT DEFAULT 350`,
+		`ALTER TABLE players ADD COLUMN IF NOT EXISTS bot_rating_volatility FLOAT DEFAULT 0.06`,
+		`ALTER TABLE players ADD COLUMN IF NOT EXISTS bot_rated_games INT DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_rating_history_username ON rating_history(username, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_games_match_id ON games(match_id) WHERE match_id IS NOT NULL`,
 		`CREATE INDEX IF NOT EXISTS idx_games_start_time ON games(start_time)`,
+		`CREATE INDEX IF NOT EXISTS idx_games_difficulty ON games(difficulty) WHERE is_bot`,
 		`CREATE INDEX IF NOT EXISTS idx_players_games_won ON players(games_won DESC)`,
 	}
 
@@ -74,6 +149,8 @@ func createTables() {
 	}
 }
 
+// SaveGame stores a finished game and its moves, and updates the players'
+// ratings, in one transaction.
 func SaveGame(game *Game) {
 	if db == nil {
 		return
@@ -82,22 +159,203 @@ func SaveGame(game *Game) {
 	boardJSON, _ := json.Marshal(game.Board)
 	duration := game.EndTime.Sub(game.StartTime).Seconds()
 
-	winner := ""
-	if game.Winner == Player1 {
-		winner = game.Player1.Username
-	} else if game.Winner == Player2 {
-		winner = game.getPlayerName(game.Player2)
-	} else {
-		winner = "Draw"
-	}
+	winner := game.winnerName()
 
-	_, err := db.Exec(`
-		INSERT INTO games (id, player1, player2, winner, board, is_bot, start_time, end_time, duration)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`, game.ID, game.Player1.Username, game.getPlayerName(game.Player2), winner, boardJSON, game.IsBot, game.StartTime, game.EndTime, duration)
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Save game error: %v", err)
+		return
+	}
+	defer tx.Rollback()
 
+	_, err = tx.Exec(`
+		INSERT INTO games (id, player1, player2, winner, board, is_bot, difficulty, bot_strategy, bot_player, match_id, match_game, result_reason, start_time, end_time, duration)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, 0), NULLIF($10, ''), NULLIF($11, 0), $12, $13, $14, $15)
+	`, game.ID, game.getPlayerName(game.Player1), game.getPlayerName(game.Player2), winner, boardJSON, game.IsBot, string(game.Difficulty), game.botStrategy(), game.BotPlayer,
+		game.matchID(), game.MatchGame, game.Reason, game.StartTime, game.EndTime, duration)
 	if err != nil {
 		log.Printf("Save game error: %v", err)
+		return
+	}
+
+	for i, move := range game.Moves {
+		_, err = tx.Exec(`
+			INSERT INTO game_moves (game_id, move_number, column_index, row_index, player, played_at, action)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+		`, game.ID, i+1, move.Column, move.Row, move.Player, move.Time, move.Action)
+		if err != nil {
+			log.Printf("Save game move error: %v", err)
+			return
+		}
+	}
+
+	if err := updateRatings(tx, game); err != nil {
+		log.Printf("Update ratings error: %v", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Save game error: %v", err)
+	}
+}
+
+// GameRecord is a finished game as stored in the database.
+type GameRecord struct {
+	ID          string     `json:"id"`
+	Player1     string     `json:"player1"`
+	Player2     string     `json:"player2"`
+	Winner      string     `json:"winner"`           // Username, "Bot" or "Draw"
+	Reason      string     `json:"reason,omitempty"` // Why the game ended; empty for older games
+	IsBot       bool       `json:"isBot"`
+	Difficulty  Difficulty `json:"difficulty,omitempty"`
+	BotStrategy string     `json:"botStrategy,omitempty"`
+	StartTime   time.Time  `json:"startTime"`
+	EndTime     time.Time  `json:"endTime"`
+	Duration    float64    `json:"duration"`
+	MoveCount   int        `json:"moveCount"`       // Discs played
+	Board       [][]int    `json:"board,omitempty"` // Final position
+	Moves       []Move     `json:"moves,omitempty"` // Discs and actions; empty for games saved before moves were recorded
+}
+
+// WinnerNumber returns the player number of the winner, or 0 for a draw.
+func (r *GameRecord) WinnerNumber() int {
+	switch r.Winner {
+	case r.Player1:
+		return Player1
+	case r.Player2:
+		return Player2
+	}
+	return Empty
+}
+
+// GetGame loads a finished game with its final board and moves.
+func GetGame(id string) (*GameRecord, error) {
+	if db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	var record GameRecord
+	var difficulty, botStrategy sql.NullString
+	var boardJSON []byte
+	err := db.QueryRow(`
+		SELECT id, player1, player2, winner, COALESCE(result_reason, ''), board, is_bot, difficulty, bot_strategy, start_time, end_time, duration
+		FROM games
+		WHERE id = $1
+	`, id).Scan(&record.ID, &record.Player1, &record.Player2, &record.Winner, &record.Reason, &boardJSON, &record.IsBot,
+		&difficulty, &botStrategy, &record.StartTime, &record.EndTime, &record.Duration)
+	if err == sql.ErrNoRows {
+		return nil, ErrGameNotFound
+	}
+	if err != nil {
+		log.Printf("Get game error: %v", err)
+		return nil, err
+	}
+	record.Difficulty = Difficulty(difficulty.String)
+	record.BotStrategy = botStrategy.String
+	if err := json.Unmarshal(boardJSON, &record.Board); err != nil {
+		log.Printf("Get game board error: %v", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT column_index, row_index, player, played_at, COALESCE(action, '')
+		FROM game_moves
+		WHERE game_id = $1
+		ORDER BY move_number
+	`, id)
+	if err != nil {
+		log.Printf("Get game moves error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var move Move
+		if err := rows.Scan(&move.Column, &move.Row, &move.Player, &move.Time, &move.Action); err != nil {
+			log.Printf("Scan error: %v", err)
+			return nil, err
+		}
+		record.Moves = append(record.Moves, move)
+	}
+	record.MoveCount = len(discMoves(record.Moves))
+	return &record, rows.Err()
+}
+
+// GetRecentGames returns the latest games of a player, without boards or
+// moves.
+func GetRecentGames(username string, limit int) []GameRecord {
+	if db == nil {
+		return []GameRecord{}
+	}
+
+	rows, err := db.Query(`
+		SELECT g.id, g.player1, g.player2, g.winner, COALESCE(g.result_reason, ''), g.is_bot, COALESCE(g.difficulty, ''), COALESCE(g.bot_strategy, ''),
+		       g.start_time, g.end_time, g.duration,
+		       (SELECT COUNT(*) FROM game_moves m WHERE m.game_id = g.id AND m.action IS NULL)
+		FROM games g
+		WHERE g.player1 = $1 OR g.player2 = $1
+		ORDER BY g.end_time DESC
+		LIMIT $2
+	`, username, limit)
+	if err != nil {
+		log.Printf("Get recent games error: %v", err)
+		return []GameRecord{}
+	}
+	defer rows.Close()
+
+	games := []GameRecord{}
+	for rows.Next() {
+		var record GameRecord
+		if err := rows.Scan(&record.ID, &record.Player1, &record.Player2, &record.Winner, &record.Reason, &record.IsBot,
+			&record.Difficulty, &record.BotStrategy, &record.StartTime, &record.EndTime, &record.Duration,
+			&record.MoveCount); err != nil {
+			log.Printf("Scan error: %v", err)
+			continue
+		}
+		games = append(games, record)
+	}
+	return games
+}
+
+// GetCachedAnalysis returns a stored analysis of a move sequence, written in
+// c4n move text, searched to depth.
+func GetCachedAnalysis(moves string, depth int) (*Analysis, bool) {
+	if db == nil {
+		return nil, false
+	}
+
+	var analysisJSON []byte
+	err := db.QueryRow(`
+		SELECT analysis FROM move_analyses WHERE moves = $1 AND depth = $2
+	`, moves, depth).Scan(&analysisJSON)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Get analysis error: %v", err)
+		}
+		return nil, false
+	}
+
+	var analysis Analysis
+	if err := json.Unmarshal(analysisJSON, &analysis); err != nil {
+		log.Printf("Get analysis error: %v", err)
+		return nil, false
+	}
+	return &analysis, true
+}
+
+// SaveAnalysis caches the analysis of a move sequence.
+func SaveAnalysis(moves string, depth int, analysis *Analysis) {
+	if db == nil {
+		return
+	}
+
+	analysisJSON, _ := json.Marshal(analysis)
+	_, err := db.Exec(`
+		INSERT INTO move_analyses (moves, depth, analysis)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (moves, depth) DO NOTHING
+	`, moves, depth, analysisJSON)
+	if err != nil {
+		log.Printf("Save analysis error: %v", err)
 	}
 }
 
@@ -122,6 +380,144 @@ func UpdatePlayerStats(username string, won bool) {
 	}
 }
 
+// GetPlayerRatings returns a player's ratings against people and against
+// the bot. New players, and every player without a database, have the
+// starting rating.
+func GetPlayerRatings(username string) (human, bot rating.Rating) {
+	human, bot = rating.New(), rating.New()
+	if db == nil {
+		return human, bot
+	}
+
+	err := db.QueryRow(`
+		SELECT rating, rating_deviation, rating_volatility, bot_rating, bot_rating_deviation, bot_rating_volatility
+		FROM players WHERE username = $1
+	`, username).Scan(&human.Rating, &human.Deviation, &human.Volatility, &bot.Rating, &bot.Deviation, &bot.Volatility)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Get player ratings error: %v", err)
+		return rating.New(), rating.New()
+	}
+	return human, bot
+}
+
+// RatingPool is a set of games players are rated on separately.
+type RatingPool string
+
+const (
+	PoolHuman RatingPool = "human" // Games between people
+	PoolBot   RatingPool = "bot"   // Games against the bot
+)
+
+// columns returns the players table columns holding a pool's ratings.
+func (p RatingPool) columns() (rating, deviation, volatility, games string) {
+	if p == PoolBot {
+		return "bot_rating", "bot_rating_deviation", "bot_rating_volatility", "bot_rated_games"
+	}
+	return "rating", "rating_deviation", "rating_volatility", "rated_games"
+}
+
+// updateRatings rates the humans in a finished game, treating every game as
+// a rating period of its own, and records the change in rating_history.
+// People are rated against each other's ratings before the game, and bot
+// games against the fixed rating of the difficulty.
+func updateRatings(tx *sql.Tx, game *Game) error {
+	pool := PoolHuman
+	if game.IsBot {
+		pool = PoolBot
+	}
+	ratingCol, deviationCol, volatilityCol, gamesCol := pool.columns()
+
+	// Lock the rows in username order so concurrent saves can't deadlock
+	players := game.humans()
+	sort.Slice(players, func(i, j int) bool { return players[i].Username < players[j].Username })
+	before := make(map[*Player]rating.Rating)
+	for _, p := range players {
+		if _, err := tx.Exec(`INSERT INTO players (username) VALUES ($1) ON CONFLICT (username) DO NOTHING`, p.Username); err != nil {
+			return err
+		}
+		var r rating.Rating
+		err := tx.QueryRow(`SELECT `+ratingCol+`, `+deviationCol+`, `+volatilityCol+` FROM players WHERE username = $1 FOR UPDATE`,
+			p.Username).Scan(&r.Rating, &r.Deviation, &r.Volatility)
+		if err != nil {
+			return err
+		}
+		before[p] = r
+	}
+
+	for _, p := range players {
+		opponent := botRatings[game.Difficulty]
+		if !game.IsBot {
+			opponent = before[game.opponentOf(p)]
+		}
+		score := rating.Loss
+		switch game.Winner {
+		case game.numberOf(p):
+			score = rating.Win
+		case Empty:
+			score = rating.Draw
+		}
+		after := before[p].Update(rating.Result{Opponent: opponent, Score: score})
+
+		_, err := tx.Exec(`
+			UPDATE players SET `+ratingCol+` = $2, `+deviationCol+` = $3, `+volatilityCol+` = $4, `+gamesCol+` = `+gamesCol+` + 1,
+				updated_at = CURRENT_TIMESTAMP
+			WHERE username = $1
+		`, p.Username, after.Rating, after.Deviation, after.Volatility)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO rating_history (game_id, username, pool, rating_before, rating_after, deviation_before, deviation_after, volatility_after)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, game.ID, p.Username, string(pool), before[p].Rating, after.Rating, before[p].Deviation, after.Deviation, after.Volatility)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveMatch stores a finished match.
+func SaveMatch(m *Match) {
+	if db == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, err := db.Exec(`
+		INSERT INTO matches (id, player1, player2, best_of, player1_wins, player2_wins, draws, winner, games_played, start_time, end_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, m.ID, m.Player1.Username, m.Player2.Username, m.BestOf, m.Wins[Player1], m.Wins[Player2], m.Draws,
+		m.playerName(m.Winner), len(m.Games), m.StartTime, m.EndTime)
+	if err != nil {
+		log.Printf("Save match error: %v", err)
+	}
+}
+
+// UpdatePlayerMatchStats credits a player with a finished match. A drawn
+// match counts as played but neither won nor lost.
+func UpdatePlayerMatchStats(username string, won, drawn bool) {
+	if db == nil {
+		return
+	}
+
+	lost := !won && !drawn
+	_, err := db.Exec(`
+		INSERT INTO players (username, matches_played, matches_won, matches_lost)
+		VALUES ($1, 1, $2, $3)
+		ON CONFLICT (username) DO UPDATE SET
+			matches_played = players.matches_played + 1,
+			matches_won = players.matches_won + $2,
+			matches_lost = players.matches_lost + $3,
+			updated_at = CURRENT_TIMESTAMP
+	`, username, boolToInt(won), boolToInt(lost))
+
+	if err != nil {
+		log.Printf("Update player match stats error: %v", err)
+	}
+}
+
 func boolToInt(b bool) int {
 	if b {
 		return 1
@@ -130,25 +526,100 @@ func boolToInt(b bool) int {
 }
 
 type LeaderboardEntry struct {
-	Username    string  `json:"username"`
-	GamesPlayed int     `json:"gamesPlayed"`
-	GamesWon    int     `json:"gamesWon"`
-	GamesLost   int     `json:"gamesLost"`
-	WinRate     float64 `json:"winRate"`
+	Username      string  `json:"username"`
+	GamesPlayed   int     `json:"gamesPlayed"`
+	GamesWon      int     `json:"gamesWon"`
+	GamesLost     int     `json:"gamesLost"`
+	WinRate       float64 `json:"winRate"`
+	MatchesPlayed int     `json:"matchesPlayed"`
+	MatchesWon    int     `json:"matchesWon"`
+	Rating        int     `json:"rating"`
+	RatedGames    int     `json:"ratedGames"`
+	BotRating     int     `json:"botRating"`
+	BotRatedGames int     `json:"botRatedGames"`
+}
+
+// LeaderboardSort is what the leaderboard ranks players by.
+type LeaderboardSort string
+
+const (
+	SortByWins      LeaderboardSort = "wins"       // Games won
+	SortByMatches   LeaderboardSort = "matches"    // Matches won, then games won
+	SortByRating    LeaderboardSort = "rating"     // Rating in games between people
+	SortByBotRating LeaderboardSort = "bot_rating" // Rating in games against the bot
+)
+
+// defaultMinRatedGames is how many rated games a player needs to appear on
+// a leaderboard sorted by rating, as new players' ratings are unreliable.
+const defaultMinRatedGames = 5
+
+// ParseLeaderboardSort parses a sort order name, defaulting to SortByWins.
+func ParseLeaderboardSort(s string) (LeaderboardSort, error) {
+	switch by := LeaderboardSort(s); by {
+	case "":
+		return SortByWins, nil
+	case SortByWins, SortByMatches, SortByRating, SortByBotRating:
+		return by, nil
+	}
+	return "", fmt.Errorf("Unknown sort %q, expected wins, matches, rating or bot_rating", s)
 }
 
-func GetLeaderboard() []LeaderboardEntry {
+// GetLeaderboard returns the top players. With a difficulty, only games
+// against the bot at that difficulty are counted, and matches, which are
+// only played between people, aren't. Sorted by rating, players need at
+// least minGames rated games in that pool.
+func GetLeaderboard(difficulty Difficulty, by LeaderboardSort, minGames int) []LeaderboardEntry {
 	if db == nil {
 		return []LeaderboardEntry{}
 	}
 
-	rows, err := db.Query(`
+	order := "games_won DESC, win_rate DESC"
+	where := ""
+	var args []interface{}
+	switch by {
+	case SortByMatches:
+		order = "matches_won DESC, games_won DESC, win_rate DESC"
+	case SortByRating:
+		order = "rating DESC, rating_deviation ASC"
+		where = "WHERE rated_games >= $1"
+		args = append(args, minGames)
+	case SortByBotRating:
+		order = "bot_rating DESC, bot_rating_deviation ASC"
+		where = "WHERE bot_rated_games >= $1"
+		args = append(args, minGames)
+	}
+
+	var rows *sql.Rows
+	var err error
+	if difficulty == "" {
+		rows, err = db.Query(`
         SELECT username, games_played, games_won, games_lost,
-               CASE WHEN games_played > 0 THEN ROUND(((games_won::float / games_played::float) * 100)::numeric, 2) ELSE 0 END as win_rate
+               CASE WHEN games_played > 0 THEN ROUND(((games_won::float / games_played::float) * 100)::numeric, 2) ELSE 0 END as win_rate,
+               matches_played, matches_won,
+               ROUND(rating)::int, rated_games, ROUND(bot_rating)::int, bot_rated_games
         FROM players
+        `+where+`
+        ORDER BY `+order+`
+        LIMIT 10
+    `, args...)
+	} else {
+		rows, err = db.Query(`
+        SELECT username, COUNT(*) AS games_played,
+               COUNT(*) FILTER (WHERE winner = username) AS games_won,
+               COUNT(*) FILTER (WHERE winner <> username AND winner <> 'Draw') AS games_lost,
+               ROUND(((COUNT(*) FILTER (WHERE winner = username))::float / COUNT(*)::float * 100)::numeric, 2) AS win_rate,
+               0 AS matches_played, 0 AS matches_won,
+               0 AS rating, 0 AS rated_games, 0 AS bot_rating, 0 AS bot_rated_games
+        FROM (
+            SELECT CASE WHEN bot_player = 1 THEN player2 ELSE player1 END AS username, winner
+            FROM games
+            WHERE is_bot = true AND difficulty = $1
+        ) AS bot_games
+        GROUP BY username
         ORDER BY games_won DESC, win_rate DESC
         LIMIT 10
-    `)
+    `, string(difficulty))
+	}
 	if err != nil {
 		log.Printf("Get leaderboard error: %v", err)
 		return []LeaderboardEntry{}
@@ -158,7 +629,9 @@ func GetLeaderboard() []LeaderboardEntry {
 	leaderboard := []LeaderboardEntry{}
 	for rows.Next() {
 		var entry LeaderboardEntry
-		if err := rows.Scan(&entry.Username, &entry.GamesPlayed, &entry.GamesWon, &entry.GamesLost, &entry.WinRate); err != nil {
+		if err := rows.Scan(&entry.Username, &entry.GamesPlayed, &entry.GamesWon, &entry.GamesLost, &entry.WinRate,
+			&entry.MatchesPlayed, &entry.MatchesWon,
+			&entry.Rating, &entry.RatedGames, &entry.BotRating, &entry.BotRatedGames); err != nil {
 			log.Printf("Scan error: %v", err)
 			continue
 		}
@@ -0,0 +1,62 @@
+package main
+
+import "time"
+
+const (
+	emoteBurst  = 3               // Emotes allowed per emoteWindow
+	emoteWindow = 5 * time.Second // Window for emoteBurst
+)
+
+// Emote is a predefined reaction players can send instead of chatting.
+type Emote struct {
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+	Emoji string `json:"emoji"`
+}
+
+// Emotes are the only reactions the server relays, in display order.
+var Emotes = []Emote{
+	{ID: "good_luck", Text: "Good luck!", Emoji: "🍀"},
+	{ID: "nice_move", Text: "Nice move!", Emoji: "👏"},
+	{ID: "oops", Text: "Oops", Emoji: "😅"},
+	{ID: "thinking", Text: "Hmm...", Emoji: "🤔"},
+	{ID: "wow", Text: "Wow!", Emoji: "😮"},
+	{ID: "good_game", Text: "Good game", Emoji: "🤝"},
+}
+
+// findEmote returns the emote with the given ID.
+func findEmote(id string) (Emote, bool) {
+	for _, e := range Emotes {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return Emote{}, false
+}
+
+// EmoteMessage is an emote as relayed to the players and spectators.
+type EmoteMessage struct {
+	GameID string `json:"gameId"`
+	From   string `json:"from"`
+	Player int    `json:"player"`
+	Emote
+}
+
+// HandleEmote relays one of the predefined emotes from a player to everyone
+// in the game.
+func (g *Game) HandleEmote(player *Player, id string) {
+	emote, ok := findEmote(id)
+	if !ok {
+		player.SendError("Unknown emote.")
+		return
+	}
+	if !player.emoteLimit.Allow(time.Now()) {
+		player.SendError("You're sending emotes too fast.")
+		return
+	}
+
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	g.broadcast("emote", EmoteMessage{GameID: g.ID, From: player.Username, Player: g.numberOf(player), Emote: emote})
+}
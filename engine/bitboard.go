@@ -0,0 +1,167 @@
+package engine
+
+import "fmt"
+
+// maxBitboardCols is the widest board a Position can hold: every column needs
+// at least two bits (one cell plus the sentinel).
+const maxBitboardCols = 32
+
+// Position is a bitboard representation of a board, cheap to copy and fast
+// enough for the bot to search millions of positions.
+//
+// Each column uses Rows+1 bits, bottom cell first; the extra sentinel bit on
+// top of every column is always zero so lines never wrap between columns.
+// Only variants with Cols*(Rows+1) <= 64 fit, which includes 7x6 and 8x7.
+type Position struct {
+	variant Variant
+	discs   [2]uint64              // One mask per player
+	height  [maxBitboardCols]uint8 // Discs in each column
+	moves   int
+	hash    uint64 // Zobrist hash, updated incrementally
+}
+
+// FitsBitboard reports whether the variant can be represented as a Position.
+func FitsBitboard(v Variant) bool {
+	return v.Validate() == nil && v.Cols*(v.Rows+1) <= 64
+}
+
+// NewPosition creates an empty position for the given variant.
+func NewPosition(v Variant) (*Position, error) {
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	if !FitsBitboard(v) {
+		return nil, fmt.Errorf("variant %s is too large for a bitboard", v)
+	}
+	return &Position{variant: v, hash: variantKey(v)}, nil
+}
+
+// NewStandardPosition creates an empty 7x6 position.
+func NewStandardPosition() *Position {
+	p, _ := NewPosition(Standard)
+	return p
+}
+
+// Variant returns the position's dimensions and connect length.
+func (p *Position) Variant() Variant {
+	return p.variant
+}
+
+// MoveCount returns the number of discs played.
+func (p *Position) MoveCount() int {
+	return p.moves
+}
+
+// Height returns the number of discs in the column.
+func (p *Position) Height(col int) int {
+	return int(p.height[col])
+}
+
+// Mask returns the bitboard of the player's discs.
+func (p *Position) Mask(player int) uint64 {
+	return p.discs[player-1]
+}
+
+// Occupied returns the bitboard of all discs.
+func (p *Position) Occupied() uint64 {
+	return p.discs[0] | p.discs[1]
+}
+
+// Hash returns the Zobrist hash of the discs on the board. It does not
+// depend on the order the moves were played in.
+func (p *Position) Hash() uint64 {
+	return p.hash
+}
+
+// Bit returns the bit for the cell height h (0 is the bottom) of col.
+func (p *Position) Bit(col, h int) uint64 {
+	return 1 << p.bitIndex(col, h)
+}
+
+func (p *Position) bitIndex(col, h int) uint {
+	return uint(col*(p.variant.Rows+1) + h)
+}
+
+// Cell returns the player occupying the given cell, or Empty. Row 0 is the
+// top row, as in Board.
+func (p *Position) Cell(row, col int) int {
+	bit := p.Bit(col, p.variant.Rows-1-row)
+	switch {
+	case p.discs[0]&bit != 0:
+		return Player1
+	case p.discs[1]&bit != 0:
+		return Player2
+	}
+	return Empty
+}
+
+// CanPlay reports whether a disc can be dropped into the column.
+func (p *Position) CanPlay(col int) bool {
+	return col >= 0 && col < p.variant.Cols && int(p.height[col]) < p.variant.Rows
+}
+
+// LegalMoves returns the playable columns, from left to right.
+func (p *Position) LegalMoves() []int {
+	moves := make([]int, 0, p.variant.Cols)
+	for c := 0; c < p.variant.Cols; c++ {
+		if p.CanPlay(c) {
+			moves = append(moves, c)
+		}
+	}
+	return moves
+}
+
+// Play drops a disc for the player into the column. The caller must check
+// CanPlay first.
+func (p *Position) Play(col, player int) {
+	i := p.bitIndex(col, int(p.height[col]))
+	p.discs[player-1] |= 1 << i
+	p.hash ^= zobristKeys[player-1][i]
+	p.height[col]++
+	p.moves++
+}
+
+// Undo removes the top disc from the column, reversing Play.
+func (p *Position) Undo(col int) {
+	p.height[col]--
+	p.moves--
+	i := p.bitIndex(col, int(p.height[col]))
+	for player := range p.discs {
+		if p.discs[player]&(1<<i) != 0 {
+			p.discs[player] &^= 1 << i
+			p.hash ^= zobristKeys[player][i]
+		}
+	}
+}
+
+// IsWin reports whether the player has Connect discs in a line.
+func (p *Position) IsWin(player int) bool {
+	return p.hasLine(p.discs[player-1])
+}
+
+// IsWinningMove reports whether dropping a disc for the player into the
+// column would win. The caller must check CanPlay first.
+func (p *Position) IsWinningMove(col, player int) bool {
+	return p.hasLine(p.discs[player-1] | p.Bit(col, int(p.height[col])))
+}
+
+// IsFull reports whether every cell is occupied.
+func (p *Position) IsFull() bool {
+	return p.moves == p.variant.Cols*p.variant.Rows
+}
+
+// hasLine checks the four directions with shifts: vertical (1), diagonal
+// (Rows), horizontal (Rows+1) and anti-diagonal (Rows+2).
+func (p *Position) hasLine(m uint64) bool {
+	h := uint(p.variant.Rows)
+	for _, shift := range [4]uint{1, h, h + 1, h + 2} {
+		line := m
+		for i := uint(1); i < uint(p.variant.Connect) && line != 0; i++ {
+			line &= m >> (i * shift)
+		}
+		if line != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,277 @@
+// Package engine implements the rules of Connect Four independently of the
+// server, so the game, the bot and any offline tools share one implementation.
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	Empty   = 0
+	Player1 = 1
+	Player2 = 2
+)
+
+var (
+	ErrInvalidColumn = errors.New("Invalid column")
+	ErrColumnFull    = errors.New("Column is full")
+)
+
+// Variant describes the board dimensions and how many discs in a row win.
+type Variant struct {
+	Cols    int `json:"cols"`
+	Rows    int `json:"rows"`
+	Connect int `json:"connect"`
+}
+
+// Standard is the classic 7x6 board with four in a row.
+var Standard = Variant{Cols: 7, Rows: 6, Connect: 4}
+
+// String formats the variant as "COLSxROWS/CONNECT", e.g. "7x6/4".
+func (v Variant) String() string {
+	return fmt.Sprintf("%dx%d/%d", v.Cols, v.Rows, v.Connect)
+}
+
+// ParseVariant parses the format produced by Variant.String.
+func ParseVariant(s string) (Variant, error) {
+	var v Variant
+	if _, err := fmt.Sscanf(s, "%dx%d/%d", &v.Cols, &v.Rows, &v.Connect); err != nil {
+		return Variant{}, fmt.Errorf("invalid variant %q", s)
+	}
+	return v, v.Validate()
+}
+
+// Validate reports whether the variant describes a playable board.
+func (v Variant) Validate() error {
+	if v.Cols < 1 || v.Rows < 1 {
+		return fmt.Errorf("invalid board size %dx%d", v.Cols, v.Rows)
+	}
+	if v.Connect < 2 || (v.Connect > v.Cols && v.Connect > v.Rows) {
+		return fmt.Errorf("connect length %d does not fit a %dx%d board", v.Connect, v.Cols, v.Rows)
+	}
+	return nil
+}
+
+// CenterOrder returns the columns ordered from the center outwards, which is
+// the usual order in which to consider moves (3, 2, 4, 1, 5, 0, 6 for 7x6).
+func (v Variant) CenterOrder() []int {
+	order := make([]int, 0, v.Cols)
+	left, right := (v.Cols-1)/2, v.Cols/2
+	for left >= 0 {
+		order = append(order, left)
+		if right != left {
+			order = append(order, right)
+		}
+		left--
+		right++
+	}
+	return order
+}
+
+// Board is a Connect Four grid. Row 0 is the top row, matching how the
+// client renders the board. Boards small enough for a bitboard keep a
+// Position in sync and use it for win detection.
+type Board struct {
+	variant Variant
+	cells   [][]int
+	heights []int     // Discs in each column
+	pos     *Position // Nil if the variant does not fit a bitboard
+	moves   int
+}
+
+// NewBoard creates an empty board for the given variant.
+func NewBoard(v Variant) (*Board, error) {
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	cells := make([][]int, v.Rows)
+	for r := range cells {
+		cells[r] = make([]int, v.Cols)
+	}
+	b := &Board{
+		variant: v,
+		cells:   cells,
+		heights: make([]int, v.Cols),
+	}
+	if FitsBitboard(v) {
+		b.pos, _ = NewPosition(v)
+	}
+	return b, nil
+}
+
+// NewStandardBoard creates an empty 7x6 board.
+func NewStandardBoard() *Board {
+	b, _ := NewBoard(Standard)
+	return b
+}
+
+// Variant returns the board's dimensions and connect length.
+func (b *Board) Variant() Variant {
+	return b.variant
+}
+
+// Cell returns the player occupying the given cell, or Empty.
+func (b *Board) Cell(row, col int) int {
+	return b.cells[row][col]
+}
+
+// MoveCount returns the number of discs on the board.
+func (b *Board) MoveCount() int {
+	return b.moves
+}
+
+// Grid returns a copy of the cells, indexed [row][col].
+func (b *Board) Grid() [][]int {
+	grid := make([][]int, len(b.cells))
+	for r, row := range b.cells {
+		grid[r] = append([]int(nil), row...)
+	}
+	return grid
+}
+
+// MarshalJSON encodes the board as its grid.
+func (b *Board) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.cells)
+}
+
+// Position returns a bitboard copy of the board, or false if the variant is
+// too large for one.
+func (b *Board) Position() (Position, bool) {
+	if b.pos == nil {
+		return Position{}, false
+	}
+	return *b.pos, true
+}
+
+// Clone returns an independent copy of the board.
+func (b *Board) Clone() *Board {
+	clone := &Board{
+		variant: b.variant,
+		cells:   b.Grid(),
+		heights: append([]int(nil), b.heights...),
+		moves:   b.moves,
+	}
+	if b.pos != nil {
+		pos := *b.pos
+		clone.pos = &pos
+	}
+	return clone
+}
+
+// CanPlay reports whether a disc can be dropped into the column.
+func (b *Board) CanPlay(col int) bool {
+	return col >= 0 && col < b.variant.Cols && b.heights[col] < b.variant.Rows
+}
+
+// LegalMoves returns the playable columns, from left to right.
+func (b *Board) LegalMoves() []int {
+	moves := make([]int, 0, b.variant.Cols)
+	for c := 0; c < b.variant.Cols; c++ {
+		if b.CanPlay(c) {
+			moves = append(moves, c)
+		}
+	}
+	return moves
+}
+
+// NextRow returns the row a disc dropped into the column would land in, or -1
+// if the column is full.
+func (b *Board) NextRow(col int) int {
+	if !b.CanPlay(col) {
+		return -1
+	}
+	return b.variant.Rows - 1 - b.heights[col]
+}
+
+// Drop places a disc for the player in the column and returns its row.
+func (b *Board) Drop(col, player int) (int, error) {
+	if col < 0 || col >= b.variant.Cols {
+		return -1, ErrInvalidColumn
+	}
+	if b.heights[col] >= b.variant.Rows {
+		return -1, ErrColumnFull
+	}
+	row := b.NextRow(col)
+	b.cells[row][col] = player
+	b.heights[col]++
+	b.moves++
+	if b.pos != nil {
+		b.pos.Play(col, player)
+	}
+	return row, nil
+}
+
+// Undo removes the top disc from the column.
+func (b *Board) Undo(col int) {
+	if col < 0 || col >= b.variant.Cols || b.heights[col] == 0 {
+		return
+	}
+	row := b.variant.Rows - b.heights[col]
+	b.cells[row][col] = Empty
+	b.heights[col]--
+	b.moves--
+	if b.pos != nil {
+		b.pos.Undo(col)
+	}
+}
+
+// CheckWin reports whether the disc at (row, col) completes a line of
+// Connect or more discs of the same player. The bitboard path checks every
+// line of that player, which is equivalent as long as play stops at the
+// first win.
+func (b *Board) CheckWin(row, col int) bool {
+	player := b.cells[row][col]
+	if player == Empty {
+		return false
+	}
+	if b.pos != nil {
+		return b.pos.IsWin(player)
+	}
+
+	directions := [4][2]int{
+		{0, 1},  // Horizontal
+		{1, 0},  // Vertical
+		{1, 1},  // Diagonal (top-left to bottom-right)
+		{-1, 1}, // Diagonal (bottom-left to top-right)
+	}
+	for _, d := range directions {
+		count := 1 + b.countFrom(row, col, d[0], d[1], player) + b.countFrom(row, col, -d[0], -d[1], player)
+		if count >= b.variant.Connect {
+			return true
+		}
+	}
+	return false
+}
+
+// countFrom counts consecutive discs of player starting next to (row, col)
+// in the direction (dr, dc).
+func (b *Board) countFrom(row, col, dr, dc, player int) int {
+	count := 0
+	for r, c := row+dr, col+dc; r >= 0 && r < b.variant.Rows && c >= 0 && c < b.variant.Cols; r, c = r+dr, c+dc {
+		if b.cells[r][c] != player {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// IsFull reports whether no more discs can be played, i.e. the game is a
+// draw unless the last move won.
+func (b *Board) IsFull() bool {
+	return b.moves == b.variant.Cols*b.variant.Rows
+}
+
+// Outcome reports whether the disc just dropped at (row, col) ended the game,
+// and if so who won. The winner is Empty when the board filled up in a draw.
+func (b *Board) Outcome(row, col int) (over bool, winner int) {
+	if b.CheckWin(row, col) {
+		return true, b.cells[row][col]
+	}
+	if b.IsFull() {
+		return true, Empty
+	}
+	return false, Empty
+}
@@ -0,0 +1,29 @@
+package engine
+
+// Zobrist keys for hashing positions: one random key per player per bit.
+// They are generated from a fixed seed so hashes are stable across runs.
+var zobristKeys [2][64]uint64
+
+func init() {
+	seed := uint64(0x4c34_2d6f_6e65_2d34)
+	for p := range zobristKeys {
+		for i := range zobristKeys[p] {
+			seed = splitmix64(seed)
+			zobristKeys[p][i] = seed
+		}
+	}
+}
+
+// splitmix64 is a small, well-mixed pseudo random step used to derive keys.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// variantKey seeds the hash of an empty board so positions from different
+// variants never share a hash.
+func variantKey(v Variant) uint64 {
+	return splitmix64(uint64(v.Cols)<<32 | uint64(v.Rows)<<16 | uint64(v.Connect))
+}
@@ -1,58 +1,121 @@
 package main
 
 import (
-	"errors"
 	"log"
 	"sync"
 	"time"
 
+	"hello-go/engine"
+
 	"github.com/gorilla/websocket"
 )
 
 const (
-	Rows = 6
-	Cols = 7
+	Empty   = engine.Empty
+	Player1 = engine.Player1
+	Player2 = engine.Player2
 )
 
+// Why a game ended, as stored with its result.
 const (
-	Empty   = 0
-	Player1 = 1
-	Player2 = 2
+	ReasonFourInARow  = "four-in-a-row"
+	ReasonBoardFull   = "board full"
+	ReasonResignation = "resignation"
+	ReasonAgreement   = "agreement" // Draw offered and accepted
+	ReasonTimeout     = "timeout"   // A player disconnected and didn't come back in time
+	ReasonAbandon     = "abandon"   // Both players disconnected and neither came back
 )
 
+// Move is one disc played in a game, or an action such as resigning or
+// offering a draw, which has an Action and no column or row.
+type Move struct {
+	Column int       `json:"column"`
+	Row    int       `json:"row"` // Row 0 is the top row
+	Player int       `json:"player"`
+	Time   time.Time `json:"time"`
+	Action string    `json:"action,omitempty"` // "resign", "offer_draw", "accept_draw" or "decline_draw"
+}
+
+// IsAction reports whether the move is an action rather than a disc.
+func (m Move) IsAction() bool {
+	return m.Action != ""
+}
+
+// discMoves returns the discs played, leaving out actions.
+func discMoves(moves []Move) []Move {
+	discs := make([]Move, 0, len(moves))
+	for _, m := range moves {
+		if !m.IsAction() {
+			discs = append(discs, m)
+		}
+	}
+	return discs
+}
+
 // Game holds the state of a single 4-in-a-Row game.
 type Game struct {
 	ID            string `json:"id"`
-	Board         [Rows][Cols]int
+	Board         *engine.Board
 	Player1       *Player
-	Player2       *Player // Nil if bot game
-	Bot           *Bot    // Nil if player game
-	IsBot         bool    `json:"isBot"`
-	CurrentPlayer int     `json:"currentPlayer"`
-	Status        string  `json:"status"` // "playing", "finished"
-	Winner        int     `json:"winner"` // 0 for draw
+	Player2       *Player    // Nil if bot game (Player1 is nil instead when the bot plays first)
+	Bot           *Bot       // Nil if player game
+	IsBot         bool       `json:"isBot"`
+	BotPlayer     int        `json:"botPlayer,omitempty"`  // The bot's player number in a bot game
+	Difficulty    Difficulty `json:"difficulty,omitempty"` // Empty if player game
+	CurrentPlayer int        `json:"currentPlayer"`
+	Status        string     `json:"status"` // "playing", "finished"
+	Winner        int        `json:"winner"` // 0 for draw
+	Reason        string     `json:"reason"` // Why the game ended, one of the Reason constants
+	Moves         []Move     `json:"moves"`  // In the order they were played
+	Series        *Series    `json:"series,omitempty"`
+	Private       bool       `json:"private"` // Started from a private room; only these games have free chat
+	Match         *Match     // Nil unless the game is part of a match
+	MatchGame     int        // Which game of the match, from 1
 	StartTime     time.Time
 	EndTime       time.Time
 	manager       *GameManager
 	mutex         sync.RWMutex
+
+	// Rematch state once the game is over
+	rematchFrom  *Player // Player waiting for an answer
+	rematchTimer *time.Timer
+	rematched    bool
+	declined     bool // A rematch was turned down, so it can't be asked again
+
+	drawOffer   int     // Player number with a draw offer open, or 0
+	offeredDraw [3]bool // Offered a draw since their last move, by number
+	away        [3]bool // Players disconnected and not yet back, by number
+
+	// Spectators have their own lock so watching a game never waits on a move
+	spectators     map[*Player]bool
+	spectatorMutex sync.Mutex
 }
 
 // GameState is a serializable representation of the game.
 type GameState struct {
-	ID            string          `json:"id"`
-	Board         [Rows][Cols]int `json:"board"`
-	Player1       string          `json:"player1"`
-	Player2       string          `json:"player2"`
-	IsBot         bool            `json:"isBot"`
-	CurrentPlayer int             `json:"currentPlayer"`
-	Status        string          `json:"status"`
-	Winner        int             `json:"winner"`
+	ID            string      `json:"id"`
+	Board         [][]int     `json:"board"`
+	Player1       string      `json:"player1"`
+	Player2       string      `json:"player2"`
+	IsBot         bool        `json:"isBot"`
+	Difficulty    Difficulty  `json:"difficulty,omitempty"`
+	CurrentPlayer int         `json:"currentPlayer"`
+	Status        string      `json:"status"`
+	Winner        int         `json:"winner"`
+	Reason        string      `json:"reason,omitempty"`    // Once the game is over
+	DrawOffer     int         `json:"drawOffer,omitempty"` // Player with a draw offer open
+	Moves         []Move      `json:"moves,omitempty"`     // Only sent once the game is over
+	Spectators    int         `json:"spectators"`
+	Series        *Series     `json:"series,omitempty"`
+	Match         *MatchState `json:"match,omitempty"`
+	Private       bool        `json:"private"` // Whether the game has free chat
 }
 
 // NewGame creates a 1v1 game.
 func NewGame(id string, manager *GameManager, p1, p2 *Player) *Game {
 	return &Game{
 		ID:            id,
+		Board:         engine.NewStandardBoard(),
 		Player1:       p1,
 		Player2:       p2,
 		IsBot:         false,
@@ -63,13 +126,17 @@ func NewGame(id string, manager *GameManager, p1, p2 *Player) *Game {
 	}
 }
 
-// NewBotGame creates a player vs bot game.
-func NewBotGame(id string, manager *GameManager, p1 *Player) *Game {
+// NewBotGame creates a player vs bot game. The bot plays the strategy named
+// in opts (see ai.Strategies) with the difficulty's settings.
+func NewBotGame(id string, manager *GameManager, p1 *Player, difficulty Difficulty, opts BotOptions) *Game {
 	return &Game{
 		ID:            id,
+		Board:         engine.NewStandardBoard(),
 		Player1:       p1,
-		Bot:           NewBot(),
+		Bot:           NewBot(difficulty, opts),
 		IsBot:         true,
+		BotPlayer:     Player2,
+		Difficulty:    difficulty,
 		CurrentPlayer: Player1,
 		Status:        "playing",
 		StartTime:     time.Now(),
@@ -77,6 +144,25 @@ func NewBotGame(id string, manager *GameManager, p1 *Player) *Game {
 	}
 }
 
+// humans returns the players in the game who aren't the bot.
+func (g *Game) humans() []*Player {
+	var players []*Player
+	for _, p := range []*Player{g.Player1, g.Player2} {
+		if p != nil {
+			players = append(players, p)
+		}
+	}
+	return players
+}
+
+// numberOf returns the player number of a human in the game.
+func (g *Game) numberOf(p *Player) int {
+	if p == g.Player1 {
+		return Player1
+	}
+	return Player2
+}
+
 // getPlayerName is a helper to get the opponent's name (human or bot).
 func (g *Game) getPlayerName(p *Player) string {
 	if p != nil {
@@ -88,27 +174,118 @@ func (g *Game) getPlayerName(p *Player) string {
 	return "Unknown"
 }
 
-// CreateState builds a serializable game state.
+// matchID returns the ID of the game's match, or "" if it isn't in one.
+func (g *Game) matchID() string {
+	if g.Match == nil {
+		return ""
+	}
+	return g.Match.ID
+}
+
+// botStrategy returns the name of the bot's strategy, or "" in a player game.
+func (g *Game) botStrategy() string {
+	if g.Bot == nil {
+		return ""
+	}
+	return g.Bot.StrategyName
+}
+
+// CreateState builds a serializable game state. The move list is included
+// once the game has finished.
 func (g *Game) CreateState() *GameState {
-	return &GameState{
+	state := &GameState{
 		ID:            g.ID,
-		Board:         g.Board,
-		Player1:       g.Player1.Username,
+		Board:         g.Board.Grid(),
+		Player1:       g.getPlayerName(g.Player1),
 		Player2:       g.getPlayerName(g.Player2),
 		IsBot:         g.IsBot,
+		Difficulty:    g.Difficulty,
 		CurrentPlayer: g.CurrentPlayer,
 		Status:        g.Status,
 		Winner:        g.Winner,
+		Reason:        g.Reason,
+		DrawOffer:     g.drawOffer,
+		Spectators:    g.SpectatorCount(),
+		Series:        g.Series,
+		Private:       g.Private,
+	}
+	if g.Match != nil {
+		state.Match = g.Match.State(g.MatchGame)
 	}
+	if g.Status == "finished" {
+		state.Moves = g.Moves
+	}
+	return state
 }
 
-// BroadcastState sends the current game state to all players in the game.
+// BroadcastState sends the current game state to all players and
+// spectators of the game.
 func (g *Game) BroadcastState() {
-	state := g.CreateState()
-	g.Player1.SendMessage("game_update", state)
+	g.broadcast("game_update", g.CreateState())
+}
+
+// broadcast sends a message to all players and spectators of the game.
+func (g *Game) broadcast(msgType string, data interface{}) {
+	for _, p := range g.humans() {
+		p.SendMessage(msgType, data)
+	}
+
+	g.forEachSpectator(func(spectator *Player) {
+		spectator.SendMessage(msgType, data)
+	})
+}
+
+// AddSpectator subscribes a connection to the game's updates and sends it the
+// current state.
+func (g *Game) AddSpectator(spectator *Player) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	g.spectatorMutex.Lock()
+	if g.spectators == nil {
+		g.spectators = make(map[*Player]bool)
+	}
+	g.spectators[spectator] = true
+	g.spectatorMutex.Unlock()
+
+	spectator.SendMessage("spectating", g.CreateState())
+	if g.Status == "playing" {
+		g.BroadcastState() // Update the spectator count
+	}
+}
+
+// RemoveSpectator unsubscribes a spectator.
+func (g *Game) RemoveSpectator(spectator *Player) {
+	g.spectatorMutex.Lock()
+	_, ok := g.spectators[spectator]
+	delete(g.spectators, spectator)
+	g.spectatorMutex.Unlock()
+	if !ok {
+		return
+	}
+
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	if g.Status == "playing" {
+		g.BroadcastState() // Update the spectator count
+	}
+}
 
-	if !g.IsBot && g.Player2 != nil {
-		g.Player2.SendMessage("game_update", state)
+// SpectatorCount returns the number of connections watching the game.
+func (g *Game) SpectatorCount() int {
+	g.spectatorMutex.Lock()
+	defer g.spectatorMutex.Unlock()
+	return len(g.spectators)
+}
+
+// forEachSpectator calls fn for every spectator. The lock is held while fn
+// runs so a spectator can't disconnect, closing its channel, halfway through
+// a message being sent to it.
+func (g *Game) forEachSpectator(fn func(spectator *Player)) {
+	g.spectatorMutex.Lock()
+	defer g.spectatorMutex.Unlock()
+	for spectator := range g.spectators {
+		fn(spectator)
 	}
 }
 
@@ -123,11 +300,11 @@ func (g *Game) HandleMove(player *Player, col int) {
 
 	// Identify which player is making the move
 	var playerNum int
-	if player == g.Player1 {
+	if player == nil && g.IsBot { // `player == nil` signifies a bot move
+		playerNum = g.BotPlayer
+	} else if player != nil && player == g.Player1 {
 		playerNum = Player1
-	} else if !g.IsBot && player == g.Player2 {
-		playerNum = Player2
-	} else if player == nil && g.IsBot { // `player == nil` signifies a bot move
+	} else if player != nil && player == g.Player2 {
 		playerNum = Player2
 	} else {
 		log.Printf("Error: Move from unassociated player %s in game %s", player.Username, g.ID)
@@ -150,25 +327,33 @@ func (g *Game) HandleMove(player *Player, col int) {
 		return
 	}
 
+	move := Move{Column: col, Row: row, Player: playerNum, Time: time.Now()}
+	g.Moves = append(g.Moves, move)
+
+	// Moving lets the player offer a draw again, and turns down any offer
+	// their opponent made
+	g.offeredDraw[playerNum] = false
+	if g.drawOffer == 3-playerNum {
+		g.drawOffer = 0
+	}
+
 	// Produce analytics event for the move
 	go ProduceEvent("move_made", map[string]interface{}{
-		"gameId":   g.ID,
-		"player":   g.getPlayerName(player),
-		"column":   col,
-		"row":      row,
-		"gameTime": time.Now().Unix(),
+		"gameId":     g.ID,
+		"player":     g.getPlayerName(player),
+		"column":     col,
+		"row":        row,
+		"moveNumber": len(discMoves(g.Moves)),
+		"gameTime":   move.Time.Unix(),
 	})
 
-	// Check for win
-	if g.checkWin(row, col, playerNum) {
-		g.endGame(playerNum)
-		g.BroadcastState()
-		return
-	}
-
-	// Check for draw
-	if g.checkDraw() {
-		g.endGame(Empty) // 0 for draw
+	// Check for a win or a full board (winner is 0 for a draw)
+	if over, winner := g.Board.Outcome(row, col); over {
+		reason := ReasonFourInARow
+		if winner == Empty {
+			reason = ReasonBoardFull
+		}
+		g.endGame(winner, reason)
 		g.BroadcastState()
 		return
 	}
@@ -180,140 +365,61 @@ func (g *Game) HandleMove(player *Player, col int) {
 	g.BroadcastState()
 
 	// If it's now the bot's turn, trigger its move
-	if g.IsBot && g.CurrentPlayer == Player2 {
+	if g.IsBot && g.CurrentPlayer == g.BotPlayer {
 		go g.Bot.MakeMove(g)
 	}
 }
 
 // makeMove places a disc on the board.
 func (g *Game) makeMove(col int, playerNum int) (int, error) {
-	if col < 0 || col >= Cols {
-		return -1, errors.New("Invalid column")
-	}
-
-	// Find the lowest empty row in this column
-	for r := Rows - 1; r >= 0; r-- {
-		if g.Board[r][col] == Empty {
-			g.Board[r][col] = playerNum
-			return r, nil
-		}
-	}
-
-	return -1, errors.New("Column is full")
-}
-
-// checkWin checks if the last move resulted in a win.
-func (g *Game) checkWin(lastRow, lastCol, playerNum int) bool {
-	// Check horizontal
-	count := 0
-	for c := 0; c < Cols; c++ {
-		if g.Board[lastRow][c] == playerNum {
-			count++
-			if count >= 4 {
-				return true
-			}
-		} else {
-			count = 0
-		}
-	}
-
-	// Check vertical
-	count = 0
-	for r := 0; r < Rows; r++ {
-		if g.Board[r][lastCol] == playerNum {
-			count++
-			if count >= 4 {
-				return true
-			}
-		} else {
-			count = 0
-		}
-	}
-
-	// Check diagonals (top-left to bottom-right)
-	count = 0
-	for r, c := lastRow-min(lastRow, lastCol), lastCol-min(lastRow, lastCol); r < Rows && c < Cols; r, c = r+1, c+1 {
-		if g.Board[r][c] == playerNum {
-			count++
-			if count >= 4 {
-				return true
-			}
-		} else {
-			count = 0
-		}
-	}
-
-	// Check diagonals (bottom-left to top-right)
-	count = 0
-	for r, c := lastRow+min(Rows-1-lastRow, lastCol), lastCol-min(Rows-1-lastRow, lastCol); r >= 0 && c < Cols; r, c = r-1, c+1 {
-		if g.Board[r][c] == playerNum {
-			count++
-			if count >= 4 {
-				return true
-			}
-		} else {
-			count = 0
-		}
-	}
-
-	return false
-}
-
-// checkDraw checks if the board is full.
-func (g *Game) checkDraw() bool {
-	for c := 0; c < Cols; c++ {
-		if g.Board[0][c] == Empty { // Only need to check the top row
-			return false
-		}
-	}
-	return true
+	return g.Board.Drop(col, playerNum)
 }
 
 // endGame concludes the game, saves stats, and updates players.
-func (g *Game) endGame(winner int) {
+func (g *Game) endGame(winner int, reason string) {
 	g.Status = "finished"
 	g.Winner = winner
+	g.Reason = reason
 	g.EndTime = time.Now()
+	g.drawOffer = 0
 
 	// Save to database
 	go SaveGame(g)
 
 	// Update player stats
-	var winnerUsername string
-	if winner == Player1 {
-		go UpdatePlayerStats(g.Player1.Username, true)
-		winnerUsername = g.Player1.Username
-		if !g.IsBot && g.Player2 != nil {
-			go UpdatePlayerStats(g.Player2.Username, false)
-		}
-	} else if winner == Player2 {
-		winnerUsername = g.getPlayerName(g.Player2)
-		go UpdatePlayerStats(g.Player1.Username, false)
-		if !g.IsBot && g.Player2 != nil {
-			go UpdatePlayerStats(g.Player2.Username, true)
-		}
-	} else {
-		winnerUsername = "Draw"
-		go UpdatePlayerStats(g.Player1.Username, false)
-		if !g.IsBot && g.Player2 != nil {
-			go UpdatePlayerStats(g.Player2.Username, false)
+	winnerUsername := g.winnerName()
+	for i, p := range []*Player{g.Player1, g.Player2} {
+		if p != nil {
+			go UpdatePlayerStats(p.Username, winner == i+1)
 		}
 	}
+	if g.Series != nil {
+		g.Series.record(winnerUsername)
+	}
+	if g.Match != nil {
+		g.manager.matchGameFinished(g.Match, g)
+	}
 
 	// Produce analytics event
 	go ProduceEvent("game_ended", map[string]interface{}{
-		"gameId":   g.ID,
-		"winner":   winnerUsername,
-		"duration": g.EndTime.Sub(g.StartTime).Seconds(),
-		"isBot":    g.IsBot,
-		"gameTime": g.EndTime.Unix(),
+		"gameId":      g.ID,
+		"winner":      winnerUsername,
+		"reason":      reason,
+		"duration":    g.EndTime.Sub(g.StartTime).Seconds(),
+		"isBot":       g.IsBot,
+		"difficulty":  g.Difficulty,
+		"botStrategy": g.botStrategy(),
+		"matchId":     g.matchID(),
+		"moves":       g.Moves,
+		"gameTime":    g.EndTime.Unix(),
 	})
 
-	// Remove game from active list
-	delete(g.manager.games, g.ID)
-	g.Player1.Game = nil
-	if !g.IsBot && g.Player2 != nil {
-		g.Player2.Game = nil
+	// Remove game from active list; the players can still ask for a rematch.
+	// That needs gm.mutex, which is locked before g.mutex, so it's done apart.
+	go g.manager.removeGame(g)
+	for _, p := range g.humans() {
+		p.Game = nil
+		p.LastGame = g
 	}
 }
 
@@ -327,6 +433,7 @@ func (g *Game) HandleDisconnect(player *Player) {
 
 	// Start reconnect timer
 	log.Printf("Starting 30s reconnect timer for %s in game %s", player.Username, g.ID)
+	g.away[g.numberOf(player)] = true
 	g.mutex.Unlock() // Unlock to allow reconnects
 
 	time.AfterFunc(reconnectTimeout, func() {
@@ -338,16 +445,18 @@ func (g *Game) HandleDisconnect(player *Player) {
 			return
 		}
 
-		log.Printf("Reconnect timer expired for %s. Forfeiting game %s.", player.Username, g.ID)
-
-		var winner int
-		if player == g.Player1 {
-			winner = Player2
+		// The opponent wins, unless they've gone too
+		playerNum := g.numberOf(player)
+		if !g.away[playerNum] {
+			return // Reconnected, and this timer is from an earlier disconnect
+		}
+		if g.away[3-playerNum] {
+			log.Printf("Reconnect timer expired for %s, whose opponent has also gone. Abandoning game %s.", player.Username, g.ID)
+			g.endGame(Empty, ReasonAbandon)
 		} else {
-			winner = Player1
+			log.Printf("Reconnect timer expired for %s. Forfeiting game %s.", player.Username, g.ID)
+			g.endGame(3-playerNum, ReasonTimeout)
 		}
-
-		g.endGame(winner)
 		g.BroadcastState()
 	})
 }
@@ -363,6 +472,7 @@ func (g *Game) HandleReconnect(oldPlayer *Player, newConn *websocket.Conn) {
 	}
 
 	log.Printf("Player %s reconnected to game %s.", oldPlayer.Username, g.ID)
+	g.away[g.numberOf(oldPlayer)] = false
 
 	// Close the old connection and channel
 	oldPlayer.mutex.Lock()
@@ -23,19 +23,39 @@ const (
 
 // GameManager manages all active games and players.
 type GameManager struct {
-	players       map[string]*Player // Keyed by username
-	games         map[string]*Game   // Keyed by game ID
-	waitingPlayer *Player
-	PrivateRooms  map[string]*Player // Keyed by room code (6-char alphanumeric)
-	mutex         sync.RWMutex
+	players        map[string]*Player     // Keyed by username
+	games          map[string]*Game       // Keyed by game ID
+	matchmaker     *Matchmaker            // Players waiting for a quick match
+	queueTimer     *time.Timer            // Ticks the matchmaker while anyone is waiting
+	PrivateRooms   map[string]*Player     // Keyed by room code (6-char alphanumeric)
+	roomBestOf     map[string]int         // Match length of private rooms hosting a match
+	roomTimers     map[string]*time.Timer // Expire private rooms nobody joins
+	BotThinking    ThinkingTime           // How long bots pause before moving
+	ChatFilter     *WordFilter            // Masks blocked words in chat; nil for none
+	ChatSpectators bool                   // Whether spectators see the players' chat
+	RematchWindow  time.Duration          // How long after a game a rematch can be agreed
+	mutex          sync.RWMutex
+
+	// Connections watching the list of live games, with their own lock
+	liveSubscribers map[*Player]bool
+	liveMutex       sync.Mutex
 }
 
 // NewGameManager creates a new game manager.
 func NewGameManager() *GameManager {
 	return &GameManager{
-		players:      make(map[string]*Player),
-		games:        make(map[string]*Game),
-		PrivateRooms: make(map[string]*Player),
+		players:        make(map[string]*Player),
+		games:          make(map[string]*Game),
+		PrivateRooms:   make(map[string]*Player),
+		roomBestOf:     make(map[string]int),
+		roomTimers:     make(map[string]*time.Timer),
+		matchmaker:     NewMatchmaker(systemClock{}, matchmakingTimeout),
+		BotThinking:    thinkingTimeFromEnv(),
+		ChatFilter:     chatFilterFromEnv(),
+		ChatSpectators: chatSpectatorsFromEnv(),
+		RematchWindow:  rematchWindowFromEnv(),
+
+		liveSubscribers: make(map[*Player]bool),
 	}
 }
 
@@ -49,22 +69,25 @@ func (gm *GameManager) UnregisterPlayer(player *Player) {
 	gm.mutex.Lock()
 	defer gm.mutex.Unlock()
 
+	// Spectators and lobby subscribers don't need a username
+	stopSpectating(player)
+	gm.unsubscribeLive(player)
+
 	if player.Username == "" {
 		return // Player never fully joined
 	}
 
 	delete(gm.players, player.Username)
 
-	// If player was in the waiting lobby
-	if gm.waitingPlayer == player {
-		gm.waitingPlayer = nil
-		log.Printf("Waiting player %s disconnected.", player.Username)
+	// If player was in the matchmaking queue
+	if gm.dequeue(player) {
+		log.Printf("Queued player %s disconnected.", player.Username)
 	}
 
 	// If player was hosting a private room, remove it
 	for roomCode, roomPlayer := range gm.PrivateRooms {
 		if roomPlayer == player {
-			delete(gm.PrivateRooms, roomCode)
+			gm.closeRoom(roomCode)
 			log.Printf("Private room %s removed due to host %s disconnect.", roomCode, player.Username)
 			break
 		}
@@ -93,14 +116,34 @@ func (gm *GameManager) HandleMessage(player *Player, rawMsg []byte) {
 	switch msg.Type {
 	case "join":
 		log.Printf("Handling join for username: %s", msg.Username) // DEBUG LOG
-		gm.handleJoin(player, msg.Username)
+		gm.handleJoin(player, msg.Username, msg.Difficulty)
+	case "leave_queue":
+		gm.handleLeaveQueue(player)
 	case "move":
 		gm.handleMove(player, msg.Column)
+	case "chat":
+		gm.handleChat(player, msg.Text)
+	case "mute_chat":
+		player.SetChatMuted(msg.Muted)
+	case "emote":
+		gm.handleEmote(player, msg.Emote)
+	case "resign", "offer_draw", "accept_draw", "decline_draw":
+		gm.handleGameAction(player, msg.Type)
+	case "rematch_request", "rematch_accept", "rematch_decline":
+		gm.handleRematch(player, msg.Type)
+	case "spectate":
+		gm.handleSpectate(player, msg.GameID, msg.Username)
+	case "subscribe_live":
+		gm.subscribeLive(player)
+	case "unsubscribe_live":
+		gm.unsubscribeLive(player)
 	case "reconnect":
 		gm.handleReconnect(player, msg.Username)
 	case "create_private_room":
 		log.Printf("Handling create_private_room for username: %s", msg.Username) // DEBUG LOG
-		gm.handleCreatePrivateRoom(player, msg.Username)
+		gm.handleCreatePrivateRoom(player, msg.Username, msg.BestOf)
+	case "cancel_private_room":
+		gm.handleCancelPrivateRoom(player)
 	case "join_private_room":
 		log.Printf("Handling join_private_room for username: %s, room: %s", msg.Username, msg.RoomCode) // DEBUG LOG
 		gm.handleJoinPrivateRoom(player, msg.Username, msg.RoomCode)
@@ -110,8 +153,9 @@ func (gm *GameManager) HandleMessage(player *Player, rawMsg []byte) {
 	}
 }
 
-// handleJoin processes a new player's request to join a game.
-func (gm *GameManager) handleJoin(player *Player, username string) {
+// handleJoin processes a new player's request to join a game. The difficulty
+// is only used if the player ends up playing the bot.
+func (gm *GameManager) handleJoin(player *Player, username, difficultyName string) {
 	log.Printf("DEBUG handleJoin: entered, username=%s", username)
 
 	if username == "" {
@@ -120,6 +164,22 @@ func (gm *GameManager) handleJoin(player *Player, username string) {
 		return
 	}
 
+	// "auto" picks the bot closest to the player's rating if nobody is found
+	var difficulty Difficulty
+	if difficultyName != "auto" {
+		var err error
+		if difficulty, err = ParseDifficulty(difficultyName); err != nil {
+			player.SendError(err.Error())
+			return
+		}
+	}
+
+	// Look the ratings up before locking, as the database may be slow
+	humanRating, botRating := GetPlayerRatings(username)
+	if difficulty == "" {
+		difficulty = difficultyFor(botRating)
+	}
+
 	gm.mutex.Lock()
 	defer gm.mutex.Unlock()
 
@@ -134,32 +194,15 @@ func (gm *GameManager) handleJoin(player *Player, username string) {
 	player.Username = username
 	gm.players[username] = player
 
-	if gm.waitingPlayer == nil {
-		// This is the first player, make them wait
-		log.Printf("DEBUG handleJoin: %s becomes waiting player", username)
-		gm.waitingPlayer = player
-		player.SendMessage("waiting", nil)
-
-		// Start the 10-second bot timer
-		log.Printf("DEBUG handleJoin: starting matchmaking timer for %s", username)
-		time.AfterFunc(matchmakingTimeout, func() {
-			log.Printf("DEBUG: Bot timer fired for %s", username)
-			gm.startBotGame(player)
-		})
-	} else {
-		// A waiting player exists, start a game
-		if gm.waitingPlayer == player {
-			return // Should not happen, but safeguard
-		}
-		log.Printf("DEBUG handleJoin: matching %s with waiting player %s", username, gm.waitingPlayer.Username)
-		opponent := gm.waitingPlayer
-		gm.waitingPlayer = nil
-		gm.startGame(opponent, player)
-	}
+	gm.enqueue(player, humanRating, difficulty)
 }
 
 // handleMove passes a move to the player's active game.
 func (gm *GameManager) handleMove(player *Player, col int) {
+	if player.Game == nil && player.Spectating != nil {
+		player.SendError("Spectators cannot make moves.")
+		return
+	}
 	if player.Game == nil {
 		player.SendError("You are not in a game.")
 		return
@@ -167,6 +210,85 @@ func (gm *GameManager) handleMove(player *Player, col int) {
 	player.Game.HandleMove(player, col)
 }
 
+// handleChat passes a chat message to the player's active game.
+func (gm *GameManager) handleChat(player *Player, text string) {
+	if player.Game == nil && player.Spectating != nil {
+		player.SendError("Spectators cannot chat.")
+		return
+	}
+	if player.Game == nil {
+		player.SendError("You are not in a game.")
+		return
+	}
+	player.Game.HandleChat(player, text)
+}
+
+// handleGameAction passes a resignation or draw offer to the player's
+// active game.
+func (gm *GameManager) handleGameAction(player *Player, action string) {
+	if player.Game == nil && player.Spectating != nil {
+		player.SendError("Spectators cannot resign or offer draws.")
+		return
+	}
+	if player.Game == nil {
+		player.SendError("You are not in a game.")
+		return
+	}
+	player.Game.HandleAction(player, action)
+}
+
+// handleEmote passes an emote to the player's active game.
+func (gm *GameManager) handleEmote(player *Player, emote string) {
+	if player.Game == nil && player.Spectating != nil {
+		player.SendError("Spectators cannot send emotes.")
+		return
+	}
+	if player.Game == nil {
+		player.SendError("You are not in a game.")
+		return
+	}
+	player.Game.HandleEmote(player, emote)
+}
+
+// handleSpectate subscribes a connection to a live game, found by its ID or
+// by the username of one of its players.
+func (gm *GameManager) handleSpectate(player *Player, gameID, username string) {
+	if player.Game != nil {
+		player.SendError("You cannot spectate while playing a game.")
+		return
+	}
+
+	gm.mutex.RLock()
+	var game *Game
+	if gameID != "" {
+		game = gm.games[gameID]
+	} else if p, ok := gm.players[username]; ok && username != "" {
+		game = p.Game
+	}
+	gm.mutex.RUnlock()
+
+	if game == nil {
+		player.SendError("Game not found. It may have already finished.")
+		return
+	}
+	if player.Spectating == game {
+		return
+	}
+	stopSpectating(player)
+
+	log.Printf("Spectator joined game %s.", game.ID)
+	player.Spectating = game
+	game.AddSpectator(player)
+}
+
+// stopSpectating unsubscribes the connection from the game it watches, if any.
+func stopSpectating(player *Player) {
+	if player.Spectating != nil {
+		player.Spectating.RemoveSpectator(player)
+		player.Spectating = nil
+	}
+}
+
 // handleReconnect attempts to rejoin a player to their disconnected game.
 func (gm *GameManager) handleReconnect(player *Player, username string) {
 	gm.mutex.Lock()
@@ -182,7 +304,11 @@ func (gm *GameManager) handleReconnect(player *Player, username string) {
 	// Game found, perform the reconnect
 	log.Printf("Player %s attempting to reconnect to game %s.", username, oldPlayer.Game.ID)
 
-	// Close the new player's connection and channels, as we're replacing the old one
+	// Close the new player's connection and channels, as we're replacing the
+	// old one. It mustn't be watching a game or the live games list when its
+	// channel closes.
+	stopSpectating(player)
+	gm.unsubscribeLive(player)
 	go func() {
 		// Drain the send channel before closing
 		for len(player.Send) > 0 {
@@ -198,15 +324,23 @@ func (gm *GameManager) handleReconnect(player *Player, username string) {
 
 // startGame creates and starts a new 1v1 game.
 func (gm *GameManager) startGame(p1, p2 *Player) {
-	gameID := uuid.New().String()
-	game := NewGame(gameID, gm, p1, p2)
-	gm.games[gameID] = game
+	gm.beginGame(NewGame(uuid.New().String(), gm, p1, p2))
+}
+
+// beginGame registers a new 1v1 game and sends the players its state. The
+// caller must hold gm.mutex.
+func (gm *GameManager) beginGame(game *Game) {
+	p1, p2 := game.Player1, game.Player2
+	gm.games[game.ID] = game
 
 	p1.Game = game
 	p2.Game = game
+	stopSpectating(p1)
+	stopSpectating(p2)
 
 	log.Printf("Starting game %s between %s and %s", game.ID, p1.Username, p2.Username)
 	game.BroadcastState()
+	gm.gameStarted(game)
 
 	// Produce analytics event
 	go ProduceEvent("game_started", map[string]interface{}{
@@ -214,36 +348,36 @@ func (gm *GameManager) startGame(p1, p2 *Player) {
 		"player1":  p1.Username,
 		"player2":  p2.Username,
 		"isBot":    false,
+		"matchId":  game.matchID(),
 		"gameTime": game.StartTime.Unix(),
 	})
 }
 
-// startBotGame is called by the timer if no opponent joins.
-func (gm *GameManager) startBotGame(player *Player) {
-	gm.mutex.Lock()
-	defer gm.mutex.Unlock()
-
-	// Check if the player is still the waiting player
-	if gm.waitingPlayer != player {
-		return // Player already got matched, do nothing
-	}
-
-	gm.waitingPlayer = nil
+// startBotGame starts a game against the bot for a player no opponent was
+// found for. The caller must hold gm.mutex.
+func (gm *GameManager) startBotGame(player *Player, difficulty Difficulty) {
 	gameID := uuid.New().String()
-	game := NewBotGame(gameID, gm, player)
+	game := NewBotGame(gameID, gm, player, difficulty, BotOptions{
+		Strategy: pickBotStrategy(difficulty),
+		Thinking: gm.BotThinking,
+	})
 	gm.games[gameID] = game
 	player.Game = game
+	stopSpectating(player)
 
-	log.Printf("Starting bot game %s for %s", game.ID, player.Username)
+	log.Printf("Starting %s bot game %s (%s) for %s", difficulty, game.ID, game.Bot.StrategyName, player.Username)
 	game.BroadcastState()
+	gm.gameStarted(game)
 
 	// Produce analytics event
 	go ProduceEvent("game_started", map[string]interface{}{
-		"gameId":   game.ID,
-		"player1":  player.Username,
-		"player2":  "Bot",
-		"isBot":    true,
-		"gameTime": game.StartTime.Unix(),
+		"gameId":      game.ID,
+		"player1":     player.Username,
+		"player2":     "Bot",
+		"isBot":       true,
+		"difficulty":  difficulty,
+		"botStrategy": game.Bot.StrategyName,
+		"gameTime":    game.StartTime.Unix(),
 	})
 }
 
@@ -270,8 +404,9 @@ func (gm *GameManager) generateRoomCode() string {
 	}
 }
 
-// handleCreatePrivateRoom creates a new private room with a unique code.
-func (gm *GameManager) handleCreatePrivateRoom(player *Player, username string) {
+// handleCreatePrivateRoom creates a new private room with a unique code. A
+// bestOf above 1 makes the game a match.
+func (gm *GameManager) handleCreatePrivateRoom(player *Player, username string, bestOf int) {
 	log.Printf("DEBUG: Entering handleCreatePrivateRoom, username: %s", username)
 
 	if username == "" {
@@ -280,6 +415,12 @@ func (gm *GameManager) handleCreatePrivateRoom(player *Player, username string)
 		return
 	}
 
+	bestOf, err := parseBestOf(bestOf)
+	if err != nil {
+		player.SendError(err.Error())
+		return
+	}
+
 	// Generate unique room code BEFORE acquiring lock to avoid deadlock
 	roomCode := gm.generateRoomCode()
 	log.Printf("DEBUG: Generated room code: %s", roomCode)
@@ -302,27 +443,19 @@ func (gm *GameManager) handleCreatePrivateRoom(player *Player, username string)
 
 	// Store player in private rooms
 	gm.PrivateRooms[roomCode] = player
-
-	log.Printf("Player %s created private room: %s", username, roomCode)
-	gm.mutex.Unlock()
-	log.Printf("DEBUG: Lock released")
-
-	// Send room code back to the client
-	log.Printf("DEBUG: About to send private_room_created message with code: %s", roomCode)
-	player.SendMessage("private_room_created", map[string]interface{}{
-		"roomCode": roomCode,
-	})
-	log.Printf("DEBUG: Sent private_room_created message")
+	if bestOf > 1 {
+		gm.roomBestOf[roomCode] = bestOf
+	}
 
 	// Start 40-second expiration timer
-	time.AfterFunc(privateRoomTimeout, func() {
+	gm.roomTimers[roomCode] = time.AfterFunc(privateRoomTimeout, func() {
 		gm.mutex.Lock()
 		defer gm.mutex.Unlock()
 
 		// Check if room still exists (not joined)
 		if roomPlayer, exists := gm.PrivateRooms[roomCode]; exists && roomPlayer == player {
 			// Room expired, clean up
-			delete(gm.PrivateRooms, roomCode)
+			gm.closeRoom(roomCode)
 			log.Printf("Private room %s expired for player %s", roomCode, username)
 
 			// Notify the player
@@ -331,6 +464,18 @@ func (gm *GameManager) handleCreatePrivateRoom(player *Player, username string)
 			})
 		}
 	})
+
+	log.Printf("Player %s created private room: %s", username, roomCode)
+	gm.mutex.Unlock()
+	log.Printf("DEBUG: Lock released")
+
+	// Send room code back to the client
+	log.Printf("DEBUG: About to send private_room_created message with code: %s", roomCode)
+	player.SendMessage("private_room_created", map[string]interface{}{
+		"roomCode": roomCode,
+		"bestOf":   bestOf,
+	})
+	log.Printf("DEBUG: Sent private_room_created message")
 }
 
 // handleJoinPrivateRoom allows a player to join an existing private room.
@@ -372,10 +517,70 @@ func (gm *GameManager) handleJoinPrivateRoom(player *Player, username, roomCode
 	gm.players[username] = player
 
 	// Remove room from private rooms (it's now matched)
-	delete(gm.PrivateRooms, roomCode)
+	bestOf, isMatch := gm.roomBestOf[roomCode]
+	gm.closeRoom(roomCode)
 
 	log.Printf("Player %s joined private room %s (host: %s)", username, roomCode, roomHost.Username)
 
-	// Start the game
-	gm.startGame(roomHost, player)
+	// Start the game, or the first game of the match
+	if isMatch {
+		gm.startMatch(roomHost, player, bestOf)
+	} else {
+		game := NewGame(uuid.New().String(), gm, roomHost, player)
+		game.Private = true
+		gm.beginGame(game)
+	}
+}
+
+// closeRoom removes a private room and stops its expiry timer. The caller
+// must hold gm.mutex.
+func (gm *GameManager) closeRoom(roomCode string) {
+	if timer := gm.roomTimers[roomCode]; timer != nil {
+		timer.Stop()
+	}
+	delete(gm.PrivateRooms, roomCode)
+	delete(gm.roomBestOf, roomCode)
+	delete(gm.roomTimers, roomCode)
+}
+
+// handleCancelPrivateRoom closes the room a player is waiting in and frees
+// their username.
+func (gm *GameManager) handleCancelPrivateRoom(player *Player) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	for roomCode, roomPlayer := range gm.PrivateRooms {
+		if roomPlayer == player {
+			gm.closeRoom(roomCode)
+			log.Printf("Player %s cancelled private room %s", player.Username, roomCode)
+			gm.releaseUsername(player)
+			player.SendMessage("private_room_cancelled", map[string]interface{}{"roomCode": roomCode})
+			return
+		}
+	}
+	player.SendError("You have no private room.")
+}
+
+// handleLeaveQueue takes a player out of the quick match queue and frees
+// their username.
+func (gm *GameManager) handleLeaveQueue(player *Player) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	if !gm.dequeue(player) {
+		player.SendError("You are not in the queue.")
+		return
+	}
+	log.Printf("Player %s left the queue.", player.Username)
+	gm.releaseUsername(player)
+	player.SendMessage("queue_left", nil)
+}
+
+// releaseUsername frees a player's username for anyone to take, leaving the
+// connection open to join again. The caller must hold gm.mutex.
+func (gm *GameManager) releaseUsername(player *Player) {
+	if gm.players[player.Username] == player {
+		delete(gm.players, player.Username)
+	}
+	player.Username = ""
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// liveGamesPageSize is how many live games a page holds by default.
+const liveGamesPageSize = 20
+
+// LiveGame summarizes a game in progress for the lobby.
+type LiveGame struct {
+	ID         string     `json:"id"`
+	Player1    string     `json:"player1"`
+	Player2    string     `json:"player2"`
+	IsBot      bool       `json:"isBot"`
+	BotPlayer  int        `json:"botPlayer,omitempty"` // The bot's player number in a bot game
+	Difficulty Difficulty `json:"difficulty,omitempty"`
+	MoveCount  int        `json:"moveCount"`
+	StartTime  time.Time  `json:"startTime"`
+	Elapsed    float64    `json:"elapsed"` // Seconds since the game started
+	Spectators int        `json:"spectators"`
+}
+
+// LiveGamesPage is one page of the games in progress, newest first.
+type LiveGamesPage struct {
+	Games []LiveGame `json:"games"`
+	Total int        `json:"total"`
+	Page  int        `json:"page"` // From 1
+	Limit int        `json:"limit"`
+}
+
+// liveSummary summarizes the game. The caller must not hold g.mutex for
+// writing.
+func (g *Game) liveSummary() LiveGame {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return LiveGame{
+		ID:         g.ID,
+		Player1:    g.getPlayerName(g.Player1),
+		Player2:    g.getPlayerName(g.Player2),
+		IsBot:      g.IsBot,
+		BotPlayer:  g.BotPlayer,
+		Difficulty: g.Difficulty,
+		MoveCount:  len(discMoves(g.Moves)),
+		StartTime:  g.StartTime,
+		Elapsed:    time.Since(g.StartTime).Seconds(),
+		Spectators: g.SpectatorCount(),
+	}
+}
+
+// LiveGames returns a page of the games in progress, newest first.
+func (gm *GameManager) LiveGames(page, limit int) LiveGamesPage {
+	gm.mutex.RLock()
+	games := make([]*Game, 0, len(gm.games))
+	for _, game := range gm.games {
+		games = append(games, game)
+	}
+	gm.mutex.RUnlock()
+
+	sort.Slice(games, func(i, j int) bool {
+		if !games[i].StartTime.Equal(games[j].StartTime) {
+			return games[i].StartTime.After(games[j].StartTime)
+		}
+		return games[i].ID < games[j].ID
+	})
+
+	result := LiveGamesPage{Games: []LiveGame{}, Total: len(games), Page: page, Limit: limit}
+	start := (page - 1) * limit
+	for i := start; i < len(games) && i < start+limit; i++ {
+		result.Games = append(result.Games, games[i].liveSummary())
+	}
+	return result
+}
+
+// subscribeLive sends the first page of live games to the connection, then
+// keeps it informed as games start and end.
+func (gm *GameManager) subscribeLive(player *Player) {
+	gm.liveMutex.Lock()
+	gm.liveSubscribers[player] = true
+	gm.liveMutex.Unlock()
+
+	log.Printf("Live games subscriber added (%d total)", gm.liveSubscriberCount())
+	player.SendMessage("live_games", gm.LiveGames(1, liveGamesPageSize))
+}
+
+// unsubscribeLive stops sending live game updates to the connection.
+func (gm *GameManager) unsubscribeLive(player *Player) {
+	gm.liveMutex.Lock()
+	defer gm.liveMutex.Unlock()
+	delete(gm.liveSubscribers, player)
+}
+
+func (gm *GameManager) liveSubscriberCount() int {
+	gm.liveMutex.Lock()
+	defer gm.liveMutex.Unlock()
+	return len(gm.liveSubscribers)
+}
+
+// notifyLive sends a message to every live games subscriber. The lock is
+// held while sending so a subscriber can't disconnect, closing its channel,
+// halfway through.
+func (gm *GameManager) notifyLive(msgType string, data interface{}) {
+	gm.liveMutex.Lock()
+	defer gm.liveMutex.Unlock()
+	for subscriber := range gm.liveSubscribers {
+		subscriber.SendMessage(msgType, data)
+	}
+}
+
+// gameStarted tells live games subscribers about a new game.
+func (gm *GameManager) gameStarted(game *Game) {
+	gm.notifyLive("live_game_added", game.liveSummary())
+}
+
+// removeGame takes a finished game off the list of games in progress and
+// tells live games subscribers it's over.
+func (gm *GameManager) removeGame(game *Game) {
+	gm.mutex.Lock()
+	delete(gm.games, game.ID)
+	gm.mutex.Unlock()
+
+	gm.gameFinished(game)
+}
+
+// gameFinished tells live games subscribers a game is over.
+func (gm *GameManager) gameFinished(game *Game) {
+	gm.notifyLive("live_game_removed", map[string]interface{}{
+		"id":     game.ID,
+		"winner": game.Winner,
+	})
+}
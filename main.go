@@ -2,9 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+
+	"hello-go/notation"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
@@ -40,6 +44,14 @@ func main() {
 	// REST endpoints
 	r.HandleFunc("/api/leaderboard", getLeaderboard).Methods("GET")
 	r.HandleFunc("/api/analytics", getAnalytics).Methods("GET")
+	r.HandleFunc("/api/games", getGames).Methods("GET")
+	r.HandleFunc("/api/games/live", getLiveGames).Methods("GET")        // Before {id}, which would match too
+	r.HandleFunc("/api/games/{id}.c4n", getGameNotation).Methods("GET") // Before {id}, which would match too
+	r.HandleFunc("/api/games/{id}", getGame).Methods("GET")
+	r.HandleFunc("/api/games/{id}/replay", getGameReplay).Methods("GET")
+	r.HandleFunc("/api/games/{id}/analysis", getGameAnalysis).Methods("GET")
+	r.HandleFunc("/api/analyze", postAnalyze).Methods("POST")
+	r.HandleFunc("/api/emotes", getEmotes).Methods("GET")
 
 	// Serve static files (frontend)
 	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./static")))
@@ -68,7 +80,35 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 }
 
 func getLeaderboard(w http.ResponseWriter, r *http.Request) {
-	leaderboard := GetLeaderboard()
+	// Optional ?difficulty= restricts the stats to bot games at that level
+	var difficulty Difficulty
+	if name := r.URL.Query().Get("difficulty"); name != "" {
+		var err error
+		if difficulty, err = ParseDifficulty(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Optional ?sort= ranks by matches won or rating instead of games won
+	by, err := ParseLeaderboardSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Optional ?minGames= changes how many rated games a rating sort requires
+	minGames := defaultMinRatedGames
+	if s := r.URL.Query().Get("minGames"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid minGames", http.StatusBadRequest)
+			return
+		}
+		minGames = n
+	}
+
+	leaderboard := GetLeaderboard(difficulty, by, minGames)
 	respondJSON(w, leaderboard)
 }
 
@@ -77,6 +117,157 @@ func getAnalytics(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, analytics)
 }
 
+// recentGamesLimit is how many games /api/games returns by default.
+const recentGamesLimit = 10
+
+func getGames(w http.ResponseWriter, r *http.Request) {
+	player := r.URL.Query().Get("player")
+	if player == "" {
+		http.Error(w, "Missing player", http.StatusBadRequest)
+		return
+	}
+	limit := recentGamesLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	respondJSON(w, GetRecentGames(player, limit))
+}
+
+func getLiveGames(w http.ResponseWriter, r *http.Request) {
+	page, limit := 1, liveGamesPageSize
+	if s := r.URL.Query().Get("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid page", http.StatusBadRequest)
+			return
+		}
+		page = n
+	}
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	respondJSON(w, gameManager.LiveGames(page, limit))
+}
+
+func getEmotes(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, Emotes)
+}
+
+func getGame(w http.ResponseWriter, r *http.Request) {
+	record, ok := loadGame(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, record)
+}
+
+func getGameReplay(w http.ResponseWriter, r *http.Request) {
+	record, ok := loadGame(w, r)
+	if !ok {
+		return
+	}
+	replay, err := BuildReplay(record)
+	if err != nil {
+		log.Printf("Replay error for game %s: %v", record.ID, err)
+		http.Error(w, "Game record is corrupt", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, replay)
+}
+
+func getGameNotation(w http.ResponseWriter, r *http.Request) {
+	record, ok := loadGame(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", record.ID+".c4n"))
+	record.Notation().WriteTo(w)
+}
+
+func getGameAnalysis(w http.ResponseWriter, r *http.Request) {
+	record, ok := loadGame(w, r)
+	if !ok {
+		return
+	}
+	analysis, err := AnalyzeRecord(r.Context(), record.Notation())
+	if err == ErrAnalysisBusy {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Printf("Analysis error for game %s: %v", record.ID, err)
+		http.Error(w, "Game record is corrupt", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, analysis)
+}
+
+// analyzeRequest is the body of POST /api/analyze: either a list of columns,
+// from 0, or a game in c4n notation.
+type analyzeRequest struct {
+	Moves    []int  `json:"moves"`
+	Notation string `json:"notation"`
+}
+
+func postAnalyze(w http.ResponseWriter, r *http.Request) {
+	var req analyzeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rec := notation.NewRecord()
+	if req.Notation != "" {
+		var err error
+		if rec, err = notation.ParseString(req.Notation); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		rec.Moves = req.Moves
+	}
+
+	analysis, err := AnalyzeRecord(r.Context(), rec)
+	if err == ErrAnalysisBusy {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	respondJSON(w, analysis)
+}
+
+// loadGame fetches the game named in the URL, writing an error response if
+// it can't.
+func loadGame(w http.ResponseWriter, r *http.Request) (*GameRecord, bool) {
+	record, err := GetGame(mux.Vars(r)["id"])
+	switch {
+	case err == ErrGameNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	case err == ErrNoDatabase:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return nil, false
+	case err != nil:
+		http.Error(w, "Failed to load game", http.StatusInternalServerError)
+		return nil, false
+	}
+	return record, true
+}
+
 func respondJSON(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// matchNextGameDelay is the pause between the games of a match.
+const matchNextGameDelay = 5 * time.Second
+
+// maxBestOf is the longest match that can be played.
+const maxBestOf = 7
+
+// parseBestOf validates the number of games in a match. 0 and 1 mean a
+// single game.
+func parseBestOf(n int) (int, error) {
+	if n == 0 {
+		return 1, nil
+	}
+	if n < 0 || n > maxBestOf || n%2 == 0 {
+		return 0, fmt.Errorf("Matches must be best of 1, 3, 5 or %d.", maxBestOf)
+	}
+	return n, nil
+}
+
+// Match is a best-of-N series of games between the same two players. The
+// players take turns to move first, starting with Player1.
+type Match struct {
+	ID        string
+	Player1   *Player
+	Player2   *Player
+	BestOf    int
+	Games     []string // IDs of the games played so far
+	Wins      [3]int   // Games won, indexed by Player1 or Player2
+	Draws     int
+	Status    string // "playing", "finished"
+	Winner    int    // Player1, Player2, or 0 for a drawn match
+	StartTime time.Time
+	EndTime   time.Time
+	nextGame  time.Time // When the next game starts, between games
+	mutex     sync.Mutex
+}
+
+// MatchState is the match as sent to clients with every game state.
+type MatchState struct {
+	ID         string         `json:"id"`
+	BestOf     int            `json:"bestOf"`
+	GameNumber int            `json:"gameNumber"` // Of the game the state belongs to, from 1
+	Wins       map[string]int `json:"wins"`       // Keyed by username
+	Draws      int            `json:"draws"`
+	Status     string         `json:"status"`
+	Winner     string         `json:"winner,omitempty"`     // Username, or "Draw", once finished
+	NextGameAt *time.Time     `json:"nextGameAt,omitempty"` // Between games
+}
+
+// NewMatch creates a match that hasn't started its first game.
+func NewMatch(p1, p2 *Player, bestOf int) *Match {
+	return &Match{
+		ID:        uuid.New().String(),
+		Player1:   p1,
+		Player2:   p2,
+		BestOf:    bestOf,
+		Status:    "playing",
+		StartTime: time.Now(),
+	}
+}
+
+// playerName returns the username of a match player, or "Draw".
+func (m *Match) playerName(num int) string {
+	switch num {
+	case Player1:
+		return m.Player1.Username
+	case Player2:
+		return m.Player2.Username
+	}
+	return "Draw"
+}
+
+// numberOf returns the match player number of a player.
+func (m *Match) numberOf(p *Player) int {
+	if p == m.Player1 {
+		return Player1
+	}
+	return Player2
+}
+
+// State summarizes the match for a client viewing its gameNumber'th game.
+func (m *Match) State(gameNumber int) *MatchState {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	state := &MatchState{
+		ID:         m.ID,
+		BestOf:     m.BestOf,
+		GameNumber: gameNumber,
+		Wins:       map[string]int{m.Player1.Username: m.Wins[Player1], m.Player2.Username: m.Wins[Player2]},
+		Draws:      m.Draws,
+		Status:     m.Status,
+	}
+	if m.Status == "finished" {
+		state.Winner = m.playerName(m.Winner)
+	} else if !m.nextGame.IsZero() {
+		next := m.nextGame
+		state.NextGameAt = &next
+	}
+	return state
+}
+
+// players returns who moves first and second in the next game.
+func (m *Match) players() (*Player, *Player) {
+	if len(m.Games)%2 == 0 {
+		return m.Player1, m.Player2
+	}
+	return m.Player2, m.Player1
+}
+
+// recordGame adds a finished game to the score and reports whether the
+// match is decided: a player has won more games than the other could still
+// catch up, or all games have been played.
+func (m *Match) recordGame(g *Game) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	switch g.Winner {
+	case Player1:
+		m.Wins[m.numberOf(g.Player1)]++
+	case Player2:
+		m.Wins[m.numberOf(g.Player2)]++
+	default:
+		m.Draws++
+	}
+
+	remaining := m.BestOf - len(m.Games)
+	switch {
+	case m.Wins[Player1] > m.Wins[Player2]+remaining:
+		m.finish(Player1)
+	case m.Wins[Player2] > m.Wins[Player1]+remaining:
+		m.finish(Player2)
+	case remaining == 0:
+		m.finish(Empty) // Level after every game, so drawn
+	default:
+		m.nextGame = time.Now().Add(matchNextGameDelay)
+		return false
+	}
+	return true
+}
+
+// finish ends the match. The caller must hold m.mutex.
+func (m *Match) finish(winner int) {
+	m.Status = "finished"
+	m.Winner = winner
+	m.EndTime = time.Now()
+	m.nextGame = time.Time{}
+}
+
+// forfeit ends the match in favour of the player who stayed.
+func (m *Match) forfeit(loser *Player) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.finish(3 - m.numberOf(loser))
+}
+
+// matchGameFinished updates the match after one of its games ends, then
+// starts the next game after a pause, or settles the match. The caller holds
+// g.mutex.
+func (gm *GameManager) matchGameFinished(m *Match, g *Game) {
+	if !m.recordGame(g) {
+		log.Printf("Match %s: game %d finished, next game in %v", m.ID, g.MatchGame, matchNextGameDelay)
+		time.AfterFunc(matchNextGameDelay, func() { gm.startNextMatchGame(m) })
+		return
+	}
+	go gm.matchFinished(m)
+}
+
+// startMatch starts the first game of a match. The caller must hold
+// gm.mutex.
+func (gm *GameManager) startMatch(p1, p2 *Player, bestOf int) {
+	m := NewMatch(p1, p2, bestOf)
+	log.Printf("Starting best-of-%d match %s between %s and %s", bestOf, m.ID, p1.Username, p2.Username)
+	gm.startMatchGame(m)
+}
+
+// startNextMatchGame starts the next game of a match, unless a player has
+// left, which forfeits the match.
+func (gm *GameManager) startNextMatchGame(m *Match) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	for _, p := range []*Player{m.Player1, m.Player2} {
+		if gm.players[p.Username] != p || p.Game != nil {
+			log.Printf("Match %s: %s is no longer available, forfeiting", m.ID, p.Username)
+			m.forfeit(p)
+			go gm.matchFinished(m)
+			return
+		}
+	}
+	gm.startMatchGame(m)
+}
+
+// startMatchGame starts the match's next game with the right player moving
+// first. The caller must hold gm.mutex.
+func (gm *GameManager) startMatchGame(m *Match) {
+	m.mutex.Lock()
+	first, second := m.players()
+	game := NewGame(uuid.New().String(), gm, first, second)
+	game.Match = m
+	game.Private = true // Matches are only played from private rooms
+	m.Games = append(m.Games, game.ID)
+	game.MatchGame = len(m.Games)
+	m.nextGame = time.Time{}
+	m.mutex.Unlock()
+
+	gm.beginGame(game)
+}
+
+// matchFinished records a decided match and tells the players who are
+// still connected.
+func (gm *GameManager) matchFinished(m *Match) {
+	m.mutex.Lock()
+	winner := m.playerName(m.Winner)
+	games := len(m.Games)
+	m.mutex.Unlock()
+
+	log.Printf("Match %s finished after %d games, winner %s", m.ID, games, winner)
+	go SaveMatch(m)
+	for _, p := range []*Player{m.Player1, m.Player2} {
+		won := m.Winner == m.numberOf(p)
+		drawn := m.Winner == Empty
+		go UpdatePlayerMatchStats(p.Username, won, drawn)
+	}
+
+	go ProduceEvent("match_ended", map[string]interface{}{
+		"matchId":  m.ID,
+		"player1":  m.Player1.Username,
+		"player2":  m.Player2.Username,
+		"bestOf":   m.BestOf,
+		"wins":     []int{m.Wins[Player1], m.Wins[Player2]},
+		"draws":    m.Draws,
+		"winner":   winner,
+		"games":    games,
+		"gameTime": m.EndTime.Unix(),
+	})
+
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+	state := m.State(games)
+	for _, p := range []*Player{m.Player1, m.Player2} {
+		if gm.players[p.Username] == p {
+			p.SendMessage("match_finished", state)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"hello-go/rating"
+)
+
+const (
+	initialSearchWindow = 100.0           // Rating difference accepted straight away
+	searchWindowGrowth  = 50.0            // Added to the window for every second of waiting
+	maxSearchWindow     = 600.0           // Widest the window gets
+	matchmakingInterval = 1 * time.Second // How often the queue is searched again
+	queueWaitSmoothing  = 0.2             // Weight of the latest wait in the average
+)
+
+// Clock tells the time. The matchmaker reads the time only through its
+// clock, so a fake one can drive it.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the real time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// searchWindow returns the rating difference a player accepts after
+// waiting for the given time.
+func searchWindow(waited time.Duration) float64 {
+	return math.Min(initialSearchWindow+searchWindowGrowth*waited.Seconds(), maxSearchWindow)
+}
+
+// difficultyFor returns the bot difficulty whose rating is closest to a
+// player's rating against the bot, preferring the easier one on a tie.
+func difficultyFor(r rating.Rating) Difficulty {
+	best := DifficultyEasy
+	for _, d := range []Difficulty{DifficultyEasy, DifficultyMedium, DifficultyHard, DifficultyPerfect} {
+		if math.Abs(botRatings[d].Rating-r.Rating) < math.Abs(botRatings[best].Rating-r.Rating) {
+			best = d
+		}
+	}
+	return best
+}
+
+// queueEntry is a player waiting in the matchmaking queue.
+type queueEntry struct {
+	player     *Player
+	rating     float64    // Rating in games between people
+	difficulty Difficulty // Bot to play if no opponent is found
+	joined     time.Time
+}
+
+// accepts reports whether two queued players are close enough in rating to
+// be paired, by both their search windows.
+func (e *queueEntry) accepts(other *queueEntry, now time.Time) bool {
+	window := math.Min(searchWindow(now.Sub(e.joined)), searchWindow(now.Sub(other.joined)))
+	return math.Abs(e.rating-other.rating) <= window
+}
+
+// Pairing is two queued players matched with each other. Player1 waited
+// longer and moves first.
+type Pairing struct {
+	Player1 *Player
+	Player2 *Player
+}
+
+// BotFallback is a queued player nobody was found for in time.
+type BotFallback struct {
+	Player     *Player
+	Difficulty Difficulty
+}
+
+// MatchResult is what a matchmaker operation decided, in the order it
+// decided it.
+type MatchResult struct {
+	Pairings []Pairing
+	Bots     []BotFallback
+}
+
+// QueueStatus tells a player waiting for a quick match where they stand.
+type QueueStatus struct {
+	Position      int     `json:"position"` // From 1, longest waiting first
+	QueueSize     int     `json:"queueSize"`
+	Waited        float64 `json:"waited"`        // Seconds
+	EstimatedWait float64 `json:"estimatedWait"` // Seconds until a game, against a person or the bot
+	SearchWindow  int     `json:"searchWindow"`  // Rating difference currently accepted
+	Rating        int     `json:"rating"`
+}
+
+// Matchmaker pairs quick match players by rating. Everyone is queued in the
+// order they join; the longest waiting player is paired first, with the
+// closest rated player both their search windows accept, and the windows
+// widen the longer they wait. Whoever is still queued after the timeout
+// plays the bot.
+//
+// A Matchmaker doesn't start games or timers itself: callers act on the
+// MatchResult of Enqueue and Tick, and call Tick regularly while anyone is
+// queued. It isn't safe for concurrent use.
+type Matchmaker struct {
+	clock   Clock
+	timeout time.Duration
+	queue   []*queueEntry // Longest waiting first
+	avgWait time.Duration // Recent time taken to find a game
+}
+
+// NewMatchmaker creates a matchmaker that falls back to the bot after
+// timeout.
+func NewMatchmaker(clock Clock, timeout time.Duration) *Matchmaker {
+	return &Matchmaker{clock: clock, timeout: timeout}
+}
+
+// Len returns the number of queued players.
+func (m *Matchmaker) Len() int {
+	return len(m.queue)
+}
+
+// Queued reports whether a player is in the queue.
+func (m *Matchmaker) Queued(player *Player) bool {
+	return m.find(player) >= 0
+}
+
+func (m *Matchmaker) find(player *Player) int {
+	for i, e := range m.queue {
+		if e.player == player {
+			return i
+		}
+	}
+	return -1
+}
+
+// Enqueue adds a player to the queue and pairs them straight away if an
+// opponent is in range. A player already queued isn't added again.
+func (m *Matchmaker) Enqueue(player *Player, r rating.Rating, difficulty Difficulty) MatchResult {
+	if !m.Queued(player) {
+		m.queue = append(m.queue, &queueEntry{player: player, rating: r.Rating, difficulty: difficulty, joined: m.clock.Now()})
+	}
+	return m.pair(m.clock.Now())
+}
+
+// Cancel removes a player from the queue and reports whether they were
+// queued.
+func (m *Matchmaker) Cancel(player *Player) bool {
+	i := m.find(player)
+	if i < 0 {
+		return false
+	}
+	m.queue = append(m.queue[:i], m.queue[i+1:]...)
+	return true
+}
+
+// Tick searches the queue again with the wider windows players have earned
+// by waiting, then hands whoever has waited for the timeout to the bot.
+func (m *Matchmaker) Tick() MatchResult {
+	now := m.clock.Now()
+	result := m.pair(now)
+
+	waiting := m.queue[:0]
+	for _, e := range m.queue {
+		if now.Sub(e.joined) >= m.timeout {
+			m.recordWait(now.Sub(e.joined))
+			result.Bots = append(result.Bots, BotFallback{Player: e.player, Difficulty: e.difficulty})
+		} else {
+			waiting = append(waiting, e)
+		}
+	}
+	m.queue = waiting
+	return result
+}
+
+// pair pairs as many queued players as it can.
+func (m *Matchmaker) pair(now time.Time) MatchResult {
+	var result MatchResult
+	for i := 0; i < len(m.queue); i++ {
+		first := m.queue[i]
+		best := -1
+		for j := i + 1; j < len(m.queue); j++ {
+			other := m.queue[j]
+			if !first.accepts(other, now) {
+				continue
+			}
+			if best < 0 || math.Abs(first.rating-other.rating) < math.Abs(first.rating-m.queue[best].rating) {
+				best = j
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		second := m.queue[best]
+		m.queue = append(m.queue[:best], m.queue[best+1:]...)
+		m.queue = append(m.queue[:i], m.queue[i+1:]...)
+		m.recordWait(now.Sub(first.joined))
+		m.recordWait(now.Sub(second.joined))
+		result.Pairings = append(result.Pairings, Pairing{Player1: first.player, Player2: second.player})
+		i-- // The next player has moved into slot i
+	}
+	return result
+}
+
+// recordWait adds how long a player waited for a game to the running
+// average the wait estimates are based on.
+func (m *Matchmaker) recordWait(waited time.Duration) {
+	if m.avgWait == 0 {
+		m.avgWait = waited
+		return
+	}
+	m.avgWait = time.Duration((1-queueWaitSmoothing)*float64(m.avgWait) + queueWaitSmoothing*float64(waited))
+}
+
+// estimatedWait guesses how much longer a player who has waited for the
+// given time will wait, from how long recent players waited. Nobody waits
+// longer than the timeout, when the bot steps in, which is also the guess
+// for anyone who has already waited longer than usual.
+func (m *Matchmaker) estimatedWait(waited time.Duration) time.Duration {
+	remaining := m.timeout - waited
+	if m.avgWait > waited && m.avgWait-waited < remaining {
+		remaining = m.avgWait - waited
+	}
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Status returns every queued player's place in the queue, longest waiting
+// first.
+func (m *Matchmaker) Status() map[*Player]QueueStatus {
+	now := m.clock.Now()
+	statuses := make(map[*Player]QueueStatus, len(m.queue))
+	for i, e := range m.queue {
+		waited := now.Sub(e.joined)
+		statuses[e.player] = QueueStatus{
+			Position:      i + 1,
+			QueueSize:     len(m.queue),
+			Waited:        waited.Seconds(),
+			EstimatedWait: m.estimatedWait(waited).Seconds(),
+			SearchWindow:  int(searchWindow(waited)),
+			Rating:        int(math.Round(e.rating)),
+		}
+	}
+	return statuses
+}
+
+// enqueue adds a player to the matchmaking queue. The caller must hold
+// gm.mutex.
+func (gm *GameManager) enqueue(player *Player, r rating.Rating, difficulty Difficulty) {
+	log.Printf("Player %s (%.0f) queued for a quick match, %d waiting", player.Username, r.Rating, gm.matchmaker.Len()+1)
+	gm.applyMatchResult(gm.matchmaker.Enqueue(player, r, difficulty))
+	if gm.matchmaker.Queued(player) {
+		player.SendMessage("waiting", nil)
+	}
+	gm.sendQueueStatus()
+
+	if gm.matchmaker.Len() > 0 && gm.queueTimer == nil {
+		gm.queueTimer = time.AfterFunc(matchmakingInterval, gm.tickQueue)
+	}
+}
+
+// dequeue removes a player from the matchmaking queue, telling the players
+// still waiting, and reports whether they were queued. The caller must hold
+// gm.mutex.
+func (gm *GameManager) dequeue(player *Player) bool {
+	if !gm.matchmaker.Cancel(player) {
+		return false
+	}
+	if gm.matchmaker.Len() == 0 && gm.queueTimer != nil {
+		gm.queueTimer.Stop()
+		gm.queueTimer = nil
+	}
+	gm.sendQueueStatus()
+	return true
+}
+
+// tickQueue runs the matchmaker every matchmakingInterval while anyone is
+// queued.
+func (gm *GameManager) tickQueue() {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	gm.applyMatchResult(gm.matchmaker.Tick())
+	gm.sendQueueStatus()
+	if gm.matchmaker.Len() > 0 {
+		gm.queueTimer = time.AfterFunc(matchmakingInterval, gm.tickQueue)
+	} else {
+		gm.queueTimer = nil
+	}
+}
+
+// applyMatchResult starts the games the matchmaker decided on. The caller
+// must hold gm.mutex.
+func (gm *GameManager) applyMatchResult(result MatchResult) {
+	for _, p := range result.Pairings {
+		log.Printf("Matched %s with %s", p.Player1.Username, p.Player2.Username)
+		gm.startGame(p.Player1, p.Player2)
+	}
+	for _, b := range result.Bots {
+		log.Printf("No opponent found for %s", b.Player.Username)
+		gm.startBotGame(b.Player, b.Difficulty)
+	}
+}
+
+// sendQueueStatus tells every queued player their position and expected
+// wait. The caller must hold gm.mutex.
+func (gm *GameManager) sendQueueStatus() {
+	for player, status := range gm.matchmaker.Status() {
+		player.SendMessage("queue_status", status)
+	}
+}
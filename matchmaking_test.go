@@ -0,0 +1,194 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"hello-go/rating"
+)
+
+// fakeClock is a Clock that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestMatchmaker() (*Matchmaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	return NewMatchmaker(clock, matchmakingTimeout), clock
+}
+
+func rated(r float64) rating.Rating {
+	return rating.Rating{Rating: r, Deviation: rating.DefaultDeviation, Volatility: rating.DefaultVolatility}
+}
+
+func TestTickFallsBackToBotAfterTimeout(t *testing.T) {
+	m, clock := newTestMatchmaker()
+	alice := &Player{Username: "alice"}
+
+	if result := m.Enqueue(alice, rated(1500), DifficultyEasy); len(result.Pairings) != 0 || len(result.Bots) != 0 {
+		t.Fatalf("Enqueue alone = %+v, want nothing decided", result)
+	}
+
+	clock.advance(matchmakingTimeout - time.Second)
+	if result := m.Tick(); len(result.Bots) != 0 {
+		t.Fatalf("Tick before the timeout = %+v, want no bot game", result)
+	}
+	if !m.Queued(alice) {
+		t.Fatal("alice left the queue before the timeout")
+	}
+
+	clock.advance(time.Second)
+	result := m.Tick()
+	if len(result.Pairings) != 0 {
+		t.Fatalf("Tick at the timeout paired %+v, want none", result.Pairings)
+	}
+	want := BotFallback{Player: alice, Difficulty: DifficultyEasy}
+	if len(result.Bots) != 1 || result.Bots[0] != want {
+		t.Fatalf("Tick at the timeout = %+v, want one bot game %+v", result.Bots, want)
+	}
+	if m.Len() != 0 {
+		t.Fatalf("Len() = %d after the bot took over, want 0", m.Len())
+	}
+}
+
+func TestEnqueuePairsPlayersInRange(t *testing.T) {
+	m, clock := newTestMatchmaker()
+	alice := &Player{Username: "alice"}
+	bob := &Player{Username: "bob"}
+	carol := &Player{Username: "carol"}
+
+	m.Enqueue(alice, rated(1500), DifficultyHard)
+	if result := m.Enqueue(carol, rated(2000), DifficultyHard); len(result.Pairings) != 0 {
+		t.Fatalf("Enqueue out of range paired %+v, want none", result.Pairings)
+	}
+
+	clock.advance(time.Second)
+	result := m.Enqueue(bob, rated(1550), DifficultyHard)
+	want := Pairing{Player1: alice, Player2: bob}
+	if len(result.Pairings) != 1 || result.Pairings[0] != want {
+		t.Fatalf("Enqueue in range = %+v, want one pairing %+v", result.Pairings, want)
+	}
+	if len(result.Bots) != 0 {
+		t.Fatalf("Enqueue in range started bot games %+v", result.Bots)
+	}
+	if m.Len() != 1 || !m.Queued(carol) {
+		t.Fatalf("queue after pairing holds %d players, want only carol", m.Len())
+	}
+}
+
+func TestCancelBeforeTick(t *testing.T) {
+	m, clock := newTestMatchmaker()
+	alice := &Player{Username: "alice"}
+
+	m.Enqueue(alice, rated(1500), DifficultyHard)
+	if !m.Cancel(alice) {
+		t.Fatal("Cancel() = false for a queued player")
+	}
+	if m.Cancel(alice) {
+		t.Fatal("Cancel() = true for a player no longer queued")
+	}
+
+	clock.advance(matchmakingTimeout)
+	if result := m.Tick(); len(result.Pairings) != 0 || len(result.Bots) != 0 {
+		t.Fatalf("Tick after Cancel = %+v, want nothing decided", result)
+	}
+	if m.Len() != 0 {
+		t.Fatalf("Len() = %d after Cancel, want 0", m.Len())
+	}
+}
+
+func TestSimultaneousJoinsPairInQueueOrder(t *testing.T) {
+	m, clock := newTestMatchmaker()
+	players := make([]*Player, 4)
+	for i, name := range []string{"alice", "bob", "carol", "dave"} {
+		players[i] = &Player{Username: name}
+		// 150 apart, wider than a new player's window
+		if result := m.Enqueue(players[i], rated(1500+150*float64(i)), DifficultyHard); len(result.Pairings) != 0 {
+			t.Fatalf("Enqueue(%s) paired %+v, want none", name, result.Pairings)
+		}
+	}
+
+	clock.advance(time.Second)
+	result := m.Tick()
+	want := []Pairing{
+		{Player1: players[0], Player2: players[1]},
+		{Player1: players[2], Player2: players[3]},
+	}
+	if len(result.Pairings) != len(want) {
+		t.Fatalf("Tick paired %+v, want %+v", result.Pairings, want)
+	}
+	for i := range want {
+		if result.Pairings[i] != want[i] {
+			t.Fatalf("pairing %d = %+v, want %+v", i, result.Pairings[i], want[i])
+		}
+	}
+	if m.Len() != 0 {
+		t.Fatalf("Len() = %d after pairing everyone, want 0", m.Len())
+	}
+}
+
+func TestSearchWindowWidensUntilPaired(t *testing.T) {
+	m, clock := newTestMatchmaker()
+	alice := &Player{Username: "alice"}
+	carol := &Player{Username: "carol"}
+
+	m.Enqueue(alice, rated(1500), DifficultyHard)
+	m.Enqueue(carol, rated(2000), DifficultyHard)
+
+	// The window reaches the 500 between them after 8 seconds
+	for i := 1; i < 8; i++ {
+		clock.advance(time.Second)
+		if result := m.Tick(); len(result.Pairings) != 0 {
+			t.Fatalf("Tick after %ds paired %+v, want none", i, result.Pairings)
+		}
+	}
+
+	clock.advance(time.Second)
+	result := m.Tick()
+	want := Pairing{Player1: alice, Player2: carol}
+	if len(result.Pairings) != 1 || result.Pairings[0] != want {
+		t.Fatalf("Tick after 8s = %+v, want one pairing %+v", result.Pairings, want)
+	}
+	if len(result.Bots) != 0 {
+		t.Fatalf("Tick after 8s started bot games %+v", result.Bots)
+	}
+}
+
+func TestStatusReportsPositionAndEstimatedWait(t *testing.T) {
+	m, clock := newTestMatchmaker()
+
+	// Two players paired after 2 seconds make that the usual wait
+	m.Enqueue(&Player{Username: "xavier"}, rated(1000), DifficultyHard)
+	m.Enqueue(&Player{Username: "yvonne"}, rated(1180), DifficultyHard)
+	clock.advance(2 * time.Second)
+	if result := m.Tick(); len(result.Pairings) != 1 {
+		t.Fatalf("Tick paired %+v, want one pairing", result.Pairings)
+	}
+
+	alice := &Player{Username: "alice"}
+	bob := &Player{Username: "bob"}
+	m.Enqueue(alice, rated(1500), DifficultyHard)
+	clock.advance(time.Second)
+	m.Enqueue(bob, rated(1900), DifficultyHard)
+
+	status := m.Status()
+	wantAlice := QueueStatus{Position: 1, QueueSize: 2, Waited: 1, EstimatedWait: 1, SearchWindow: 150, Rating: 1500}
+	if status[alice] != wantAlice {
+		t.Fatalf("Status()[alice] = %+v, want %+v", status[alice], wantAlice)
+	}
+	wantBob := QueueStatus{Position: 2, QueueSize: 2, Waited: 0, EstimatedWait: 2, SearchWindow: 100, Rating: 1900}
+	if status[bob] != wantBob {
+		t.Fatalf("Status()[bob] = %+v, want %+v", status[bob], wantBob)
+	}
+
+	// Past the usual wait, only the bot's timeout is certain
+	clock.advance(2 * time.Second)
+	status = m.Status()
+	if got, want := status[alice].EstimatedWait, (matchmakingTimeout - 3*time.Second).Seconds(); got != want {
+		t.Fatalf("EstimatedWait after 3s = %v, want %v", got, want)
+	}
+}
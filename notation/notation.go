@@ -0,0 +1,328 @@
+// Package notation reads and writes games in c4n, a plain text notation for
+// Connect Four modelled on chess PGN: a few header tags followed by the
+// columns played, numbered from 1. On boards up to 9 columns wide each move
+// is a single digit, so a game reads "4453...":
+//
+//	[Player1 "alice"]
+//	[Player2 "bob"]
+//	[Date "2025.01.31"]
+//	[Result "1-0"]
+//	[Variant "7x6/4"]
+//
+//	4142434 1-0
+//
+// Wider boards separate the columns with spaces. Headers and the trailing
+// result are optional, so a bare column sequence is also a valid game.
+package notation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"hello-go/engine"
+)
+
+// Result is the outcome of a game as written in the Result tag.
+type Result string
+
+const (
+	Player1Wins Result = "1-0"
+	Player2Wins Result = "0-1"
+	Draw        Result = "1/2-1/2"
+	Unknown     Result = "*" // Unfinished, or the result wasn't recorded
+)
+
+// ResultFor returns the result for a winning player, or Draw for Empty.
+func ResultFor(winner int) Result {
+	switch winner {
+	case engine.Player1:
+		return Player1Wins
+	case engine.Player2:
+		return Player2Wins
+	}
+	return Draw
+}
+
+// Winner returns the winning player, or Empty for a draw or unknown result.
+func (r Result) Winner() int {
+	switch r {
+	case Player1Wins:
+		return engine.Player1
+	case Player2Wins:
+		return engine.Player2
+	}
+	return engine.Empty
+}
+
+func parseResult(s string) (Result, bool) {
+	switch r := Result(s); r {
+	case Player1Wins, Player2Wins, Draw, Unknown:
+		return r, true
+	}
+	return "", false
+}
+
+// dateFormat is the format of the Date tag.
+const dateFormat = "2006.01.02"
+
+// Tag is a header tag other than the ones Record has fields for.
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Record is a game in c4n notation.
+type Record struct {
+	Player1 string
+	Player2 string
+	Date    time.Time // Zero if unknown
+	Result  Result
+	Variant engine.Variant
+	Tags    []Tag // Other tags, in the order they appear
+	Moves   []int // Columns played, from 0
+}
+
+// NewRecord creates a record of an unfinished standard game with no moves.
+func NewRecord() *Record {
+	return &Record{Result: Unknown, Variant: engine.Standard}
+}
+
+// Tag returns the value of an extra tag, or "" if it isn't set.
+func (r *Record) Tag(name string) string {
+	for _, t := range r.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// SetTag sets an extra tag, replacing any previous value.
+func (r *Record) SetTag(name, value string) {
+	for i := range r.Tags {
+		if r.Tags[i].Name == name {
+			r.Tags[i].Value = value
+			return
+		}
+	}
+	r.Tags = append(r.Tags, Tag{name, value})
+}
+
+// EncodeMoves writes the columns played in move text: one digit per move
+// for boards up to 9 columns wide, space-separated numbers otherwise.
+func EncodeMoves(moves []int, v engine.Variant) string {
+	var sb strings.Builder
+	for i, col := range moves {
+		if v.Cols > 9 && i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strconv.Itoa(col + 1))
+	}
+	return sb.String()
+}
+
+// DecodeMoves parses move text written by EncodeMoves. It only checks that
+// the columns exist; Record.Replay checks the moves are legal.
+func DecodeMoves(s string, v engine.Variant) ([]int, error) {
+	var tokens []string
+	if v.Cols > 9 {
+		tokens = strings.Fields(s)
+	} else {
+		for _, c := range s {
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				tokens = append(tokens, string(c))
+			}
+		}
+	}
+
+	moves := make([]int, 0, len(tokens))
+	for i, token := range tokens {
+		col, err := strconv.Atoi(token)
+		if err != nil || col < 1 || col > v.Cols {
+			return nil, fmt.Errorf("move %d: invalid column %q", i+1, token)
+		}
+		moves = append(moves, col-1)
+	}
+	return moves, nil
+}
+
+// String formats the record in c4n notation.
+func (r *Record) String() string {
+	var sb strings.Builder
+	r.WriteTo(&sb)
+	return sb.String()
+}
+
+// WriteTo writes the record in c4n notation.
+func (r *Record) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	writeTag := func(name, value string) {
+		fmt.Fprintf(&sb, "[%s %s]\n", name, strconv.Quote(value))
+	}
+	result := r.Result
+	if result == "" {
+		result = Unknown
+	}
+
+	writeTag("Player1", r.Player1)
+	writeTag("Player2", r.Player2)
+	if r.Date.IsZero() {
+		writeTag("Date", "????.??.??")
+	} else {
+		writeTag("Date", r.Date.Format(dateFormat))
+	}
+	writeTag("Result", string(result))
+	writeTag("Variant", r.Variant.String())
+	for _, t := range r.Tags {
+		writeTag(t.Name, t.Value)
+	}
+	sb.WriteByte('\n')
+	if len(r.Moves) > 0 {
+		sb.WriteString(EncodeMoves(r.Moves, r.Variant))
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(string(result))
+	sb.WriteByte('\n')
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// Parse reads a game in c4n notation. Missing tags default to an unfinished
+// standard game between unnamed players.
+func Parse(rd io.Reader) (*Record, error) {
+	r := NewRecord()
+	var moveText []string
+	resultTag, haveResultTag := Unknown, false
+
+	scanner := bufio.NewScanner(rd)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(text, "[") {
+			if i := strings.IndexByte(text, ';'); i >= 0 {
+				text = text[:i] // Comment
+			}
+			if text = strings.TrimSpace(text); text != "" {
+				moveText = append(moveText, text)
+			}
+			continue
+		}
+		if len(moveText) > 0 {
+			return nil, fmt.Errorf("line %d: tag after the moves", line)
+		}
+
+		name, value, err := parseTag(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		switch name {
+		case "Player1":
+			r.Player1 = value
+		case "Player2":
+			r.Player2 = value
+		case "Date":
+			if d, err := time.Parse(dateFormat, value); err == nil {
+				r.Date = d
+			} else if !strings.Contains(value, "?") {
+				return nil, fmt.Errorf("line %d: invalid date %q", line, value)
+			}
+		case "Result":
+			result, ok := parseResult(value)
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid result %q", line, value)
+			}
+			resultTag, haveResultTag = result, true
+		case "Variant":
+			v, err := engine.ParseVariant(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			r.Variant = v
+		default:
+			r.SetTag(name, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// The move text may end with the result, which must agree with the tag
+	text := strings.Join(moveText, " ")
+	r.Result = resultTag
+	if fields := strings.Fields(text); len(fields) > 0 {
+		if result, ok := parseResult(fields[len(fields)-1]); ok {
+			if haveResultTag && result != resultTag {
+				return nil, fmt.Errorf("result %s after the moves contradicts Result tag %s", result, resultTag)
+			}
+			r.Result = result
+			text = strings.Join(fields[:len(fields)-1], " ")
+		}
+	}
+
+	moves, err := DecodeMoves(text, r.Variant)
+	if err != nil {
+		return nil, err
+	}
+	r.Moves = moves
+	return r, nil
+}
+
+// ParseString parses a game from a string.
+func ParseString(s string) (*Record, error) {
+	return Parse(strings.NewReader(s))
+}
+
+// parseTag parses a `[Name "value"]` header line.
+func parseTag(line string) (string, string, error) {
+	if !strings.HasSuffix(line, "]") {
+		return "", "", errors.New("unterminated tag")
+	}
+	name, quoted, ok := strings.Cut(strings.TrimSpace(line[1:len(line)-1]), " ")
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid tag %q", line)
+	}
+	value, err := strconv.Unquote(strings.TrimSpace(quoted))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid value in tag %q", line)
+	}
+	return name, value, nil
+}
+
+// Replay plays the moves on an empty board, with the same validation as
+// moves in a live game. It returns the position before the first move and
+// after every move, and fails on an illegal move, a move after the game
+// ended, or a result the moves contradict. A recorded win the moves don't
+// reach is allowed, since games can also end by forfeit.
+func (r *Record) Replay() ([]*engine.Board, error) {
+	board, err := engine.NewBoard(r.Variant)
+	if err != nil {
+		return nil, err
+	}
+	positions := []*engine.Board{board.Clone()}
+
+	over, winner := false, engine.Empty
+	for i, col := range r.Moves {
+		if over {
+			return nil, fmt.Errorf("move %d: the game is already over", i+1)
+		}
+		player := i%2 + 1
+		row, err := board.Drop(col, player)
+		if err != nil {
+			return nil, fmt.Errorf("move %d (column %d): %w", i+1, col+1, err)
+		}
+		over, winner = board.Outcome(row, col)
+		positions = append(positions, board.Clone())
+	}
+
+	if over && r.Result != Unknown && r.Result != "" && r.Result != ResultFor(winner) {
+		return nil, fmt.Errorf("result %s contradicts the moves, which end in %s", r.Result, ResultFor(winner))
+	}
+	return positions, nil
+}
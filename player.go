@@ -20,22 +20,34 @@ const (
 
 // Message is a struct for WebSocket messages
 type Message struct {
-	Type     string          `json:"type"`
-	Username string          `json:"username,omitempty"`
-	Column   int             `json:"column,omitempty"`
-	RoomCode string          `json:"roomCode,omitempty"` // For private room feature
-	Data     json.RawMessage `json:"data,omitempty"`
+	Type       string          `json:"type"`
+	Username   string          `json:"username,omitempty"`
+	Column     int             `json:"column,omitempty"`
+	RoomCode   string          `json:"roomCode,omitempty"`   // For private room feature
+	Difficulty string          `json:"difficulty,omitempty"` // Bot difficulty for "join"
+	GameID     string          `json:"gameId,omitempty"`     // Game to watch for "spectate"
+	Text       string          `json:"text,omitempty"`       // Message for "chat"
+	Muted      bool            `json:"muted,omitempty"`      // For "mute_chat"
+	Emote      string          `json:"emote,omitempty"`      // Emote ID for "emote"
+	BestOf     int             `json:"bestOf,omitempty"`     // Match length for "create_private_room"
+	Data       json.RawMessage `json:"data,omitempty"`
 }
 
 // Player represents a single connected user.
 type Player struct {
-	ID       string
-	Username string
-	Conn     *websocket.Conn
-	Game     *Game
-	Manager  *GameManager
-	Send     chan []byte
-	mutex    sync.Mutex
+	ID         string
+	Username   string
+	Conn       *websocket.Conn
+	Game       *Game
+	Spectating *Game // Game being watched, if any
+	LastGame   *Game // Last finished game, for rematches
+	Manager    *GameManager
+	Send       chan []byte
+	mutex      sync.Mutex
+
+	chatMuted  bool         // Don't receive other people's chat
+	chatLimit  *rateLimiter // Chat messages sent recently
+	emoteLimit *rateLimiter // Emotes sent recently
 }
 
 // NewPlayer creates a new player instance.
@@ -44,6 +56,9 @@ func NewPlayer(conn *websocket.Conn, manager *GameManager) *Player {
 		Conn:    conn,
 		Manager: manager,
 		Send:    make(chan []byte, 256),
+
+		chatLimit:  newRateLimiter(chatBurst, chatWindow),
+		emoteLimit: newRateLimiter(emoteBurst, emoteWindow),
 	}
 }
 
@@ -116,6 +131,21 @@ func (p *Player) SendMessage(msgType string, data interface{}) {
 	log.Printf("DEBUG SendMessage: sent to channel") // DEBUG
 }
 
+// ChatMuted reports whether the player has muted chat.
+func (p *Player) ChatMuted() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.chatMuted
+}
+
+// SetChatMuted mutes or unmutes chat for the player.
+func (p *Player) SetChatMuted(muted bool) {
+	p.mutex.Lock()
+	p.chatMuted = muted
+	p.mutex.Unlock()
+	p.SendMessage("chat_muted", map[string]bool{"muted": muted})
+}
+
 // SendError sends an error message to the player.
 func (p *Player) SendError(message string) {
 	p.SendMessage("error", map[string]string{"message": message})
@@ -0,0 +1,144 @@
+// Package rating implements the Glicko-2 rating system
+// (http://www.glicko.net/glicko/glicko2.pdf). A player's strength is a
+// rating, how sure we are of it is the rating deviation, and how erratic
+// their results are is the volatility. Winning against a stronger opponent
+// gains more than against a weaker one, and new players, whose deviation is
+// high, move faster than established ones.
+package rating
+
+import "math"
+
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+
+	// Tau limits how much the volatility can change in one rating period.
+	// Glickman suggests 0.3 to 1.2; lower is steadier.
+	Tau = 0.5
+
+	scale       = 173.7178 // Between the Glicko and Glicko-2 scales
+	convergence = 0.000001 // Tolerance of the volatility iteration
+)
+
+// Scores of a game from the rated player's point of view.
+const (
+	Loss = 0.0
+	Draw = 0.5
+	Win  = 1.0
+)
+
+// Rating is a player's Glicko-2 rating on the familiar Glicko scale, where
+// new players start at 1500.
+type Rating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+// New returns the rating of a player with no games.
+func New() Rating {
+	return Rating{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// Result is one game in a rating period.
+type Result struct {
+	Opponent Rating  // The opponent's rating before the period
+	Score    float64 // Win, Draw or Loss
+}
+
+// Update returns the rating after a rating period with the given results.
+// Without results only the deviation changes, growing as the rating becomes
+// less certain.
+func (r Rating) Update(results ...Result) Rating {
+	mu := (r.Rating - DefaultRating) / scale
+	phi := r.Deviation / scale
+	sigma := r.Volatility
+
+	if len(results) == 0 {
+		return Rating{Rating: r.Rating, Deviation: capDeviation(math.Sqrt(phi*phi+sigma*sigma) * scale), Volatility: sigma}
+	}
+
+	// The estimated variance of the rating from the results alone, and the
+	// improvement they suggest
+	var invV, sum float64
+	for _, res := range results {
+		muJ := (res.Opponent.Rating - DefaultRating) / scale
+		g := gFactor(res.Opponent.Deviation / scale)
+		e := expected(mu, muJ, g)
+		invV += g * g * e * (1 - e)
+		sum += g * (res.Score - e)
+	}
+	v := 1 / invV
+	delta := v * sum
+
+	sigma = newVolatility(phi, sigma, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * sum
+
+	return Rating{
+		Rating:     mu*scale + DefaultRating,
+		Deviation:  capDeviation(phi * scale),
+		Volatility: sigma,
+	}
+}
+
+// Expected returns the score r is expected to get against opponent, from 0
+// for a certain loss to 1 for a certain win.
+func (r Rating) Expected(opponent Rating) float64 {
+	g := gFactor(opponent.Deviation / scale)
+	return expected((r.Rating-DefaultRating)/scale, (opponent.Rating-DefaultRating)/scale, g)
+}
+
+// gFactor reduces the weight of a result against an opponent whose rating is
+// uncertain.
+func gFactor(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func expected(mu, muJ, g float64) float64 {
+	return 1 / (1 + math.Exp(-g*(mu-muJ)))
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm,
+// step 5 of Glickman's paper.
+func newVolatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(Tau*Tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*Tau) < 0 {
+			k++
+		}
+		B = a - k*Tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > convergence {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
+
+// capDeviation keeps a deviation from growing past that of a new player.
+func capDeviation(d float64) float64 {
+	return math.Min(d, DefaultDeviation)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"hello-go/engine"
+
+	"github.com/google/uuid"
+)
+
+// defaultRematchWindow is how long after a game ends a rematch can be
+// requested and accepted, unless REMATCH_WINDOW says otherwise.
+const defaultRematchWindow = 30 * time.Second
+
+// rematchWindowFromEnv reads the rematch window from REMATCH_WINDOW, e.g. "1m".
+func rematchWindowFromEnv() time.Duration {
+	s := os.Getenv("REMATCH_WINDOW")
+	if s == "" {
+		return defaultRematchWindow
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		log.Printf("Ignoring REMATCH_WINDOW: invalid duration %q", s)
+		return defaultRematchWindow
+	}
+	return d
+}
+
+// Series is the running score of games between the same two opponents.
+type Series struct {
+	Games int            `json:"games"`
+	Wins  map[string]int `json:"wins"` // Keyed by username, "Bot" for the bot
+	Draws int            `json:"draws"`
+}
+
+// record adds a finished game to the score.
+func (s *Series) record(winnerName string) {
+	s.Games++
+	if winnerName == "Draw" {
+		s.Draws++
+	} else {
+		s.Wins[winnerName]++
+	}
+}
+
+// winnerName returns the winner's name, or "Draw".
+func (g *Game) winnerName() string {
+	switch g.Winner {
+	case Player1:
+		return g.getPlayerName(g.Player1)
+	case Player2:
+		return g.getPlayerName(g.Player2)
+	}
+	return "Draw"
+}
+
+// opponentOf returns the other human in the game, or nil against the bot.
+func (g *Game) opponentOf(player *Player) *Player {
+	if player == g.Player1 {
+		return g.Player2
+	}
+	return g.Player1
+}
+
+// handleRematch processes rematch_request, rematch_accept and
+// rematch_decline for the last game the player finished.
+func (gm *GameManager) handleRematch(player *Player, action string) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	old := player.LastGame
+	if old == nil || player.Game != nil || gm.players[player.Username] != player {
+		player.SendError("No finished game to rematch.")
+		return
+	}
+
+	old.mutex.Lock()
+	defer old.mutex.Unlock()
+
+	if old.rematched {
+		player.SendError("A rematch has already started.")
+		return
+	}
+	if old.Match != nil {
+		player.SendError("Matches continue automatically.")
+		return
+	}
+	if old.declined {
+		player.SendError("The rematch was declined.")
+		return
+	}
+	if time.Now().After(old.EndTime.Add(gm.RematchWindow)) {
+		player.SendError("The rematch window has closed.")
+		return
+	}
+
+	opponent := old.opponentOf(player)
+	if opponent != nil && (gm.players[opponent.Username] != opponent || opponent.Game != nil || opponent.LastGame != old) {
+		old.cancelRematch()
+		player.SendError("Your opponent is no longer available.")
+		return
+	}
+
+	switch action {
+	case "rematch_request":
+		if opponent == nil || old.rematchFrom == opponent {
+			gm.startRematch(old) // The bot always accepts, and if both asked it's agreed
+			return
+		}
+		if old.rematchFrom == player {
+			return // Already asked
+		}
+
+		deadline := old.EndTime.Add(gm.RematchWindow)
+		old.rematchFrom = player
+		old.rematchTimer = time.AfterFunc(time.Until(deadline), func() { gm.expireRematch(old, player) })
+		log.Printf("Player %s requested a rematch of game %s.", player.Username, old.ID)
+		player.SendMessage("rematch_sent", map[string]interface{}{"expiresAt": deadline})
+		opponent.SendMessage("rematch_offered", map[string]interface{}{"from": player.Username, "expiresAt": deadline})
+
+	case "rematch_accept":
+		if opponent == nil || old.rematchFrom != opponent {
+			player.SendError("Your opponent hasn't asked for a rematch.")
+			return
+		}
+		gm.startRematch(old)
+
+	case "rematch_decline":
+		if opponent == nil || old.rematchFrom != opponent {
+			player.SendError("Your opponent hasn't asked for a rematch.")
+			return
+		}
+		old.cancelRematch()
+		old.declined = true
+		log.Printf("Player %s declined a rematch of game %s.", player.Username, old.ID)
+		opponent.SendMessage("rematch_declined", map[string]interface{}{"by": player.Username})
+	}
+}
+
+// cancelRematch withdraws a pending rematch request. The caller must hold
+// g.mutex.
+func (g *Game) cancelRematch() {
+	if g.rematchTimer != nil {
+		g.rematchTimer.Stop()
+		g.rematchTimer = nil
+	}
+	g.rematchFrom = nil
+}
+
+// expireRematch tells the players a rematch request went unanswered.
+func (gm *GameManager) expireRematch(old *Game, requester *Player) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+	old.mutex.Lock()
+	defer old.mutex.Unlock()
+
+	if old.rematched || old.rematchFrom != requester {
+		return
+	}
+	old.cancelRematch()
+	for _, p := range []*Player{requester, old.opponentOf(requester)} {
+		if p != nil && gm.players[p.Username] == p {
+			p.SendMessage("rematch_expired", nil)
+		}
+	}
+}
+
+// startRematch starts a new game between the same opponents with colours
+// swapped, continuing the series score. The caller must hold gm.mutex and
+// old.mutex.
+func (gm *GameManager) startRematch(old *Game) {
+	old.cancelRematch()
+	old.rematched = true
+
+	if old.Series == nil {
+		old.Series = &Series{Wins: make(map[string]int)}
+		old.Series.record(old.winnerName())
+	}
+
+	game := &Game{
+		ID:            uuid.New().String(),
+		Board:         engine.NewStandardBoard(),
+		Player1:       old.Player2,
+		Player2:       old.Player1,
+		IsBot:         old.IsBot,
+		Difficulty:    old.Difficulty,
+		CurrentPlayer: Player1,
+		Status:        "playing",
+		Series:        old.Series,
+		Private:       old.Private,
+		StartTime:     time.Now(),
+		manager:       gm,
+	}
+	if old.IsBot {
+		// Strategies may keep state between moves, so the bot starts afresh
+		game.Bot = NewBot(old.Difficulty, BotOptions{Strategy: old.Bot.StrategyName, Thinking: gm.BotThinking})
+		game.BotPlayer = 3 - old.BotPlayer
+	}
+	gm.games[game.ID] = game
+
+	for _, p := range game.humans() {
+		p.Game = game
+		stopSpectating(p)
+	}
+
+	log.Printf("Starting rematch %s of game %s (game %d of the series)", game.ID, old.ID, game.Series.Games+1)
+	game.BroadcastState()
+	gm.gameStarted(game)
+
+	go ProduceEvent("game_started", map[string]interface{}{
+		"gameId":      game.ID,
+		"player1":     game.getPlayerName(game.Player1),
+		"player2":     game.getPlayerName(game.Player2),
+		"isBot":       game.IsBot,
+		"difficulty":  game.Difficulty,
+		"botStrategy": game.botStrategy(),
+		"rematchOf":   old.ID,
+		"gameTime":    game.StartTime.Unix(),
+	})
+
+	if game.IsBot && game.BotPlayer == Player1 {
+		go game.Bot.MakeMove(game)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"hello-go/engine"
+)
+
+// Replay is a finished game broken down into the position after each move,
+// for stepping through it in the replay viewer.
+type Replay struct {
+	Game      *GameRecord      `json:"game"`
+	Result    int              `json:"result"` // Winning player, 0 for a draw
+	Positions []ReplayPosition `json:"positions"`
+}
+
+// ReplayPosition is the board after a move. The first position is the empty
+// board before any move.
+type ReplayPosition struct {
+	MoveNumber int     `json:"moveNumber"`
+	Move       *Move   `json:"move,omitempty"`
+	Board      [][]int `json:"board"`
+	Elapsed    float64 `json:"elapsed"` // Seconds since the game started
+}
+
+// BuildReplay replays the recorded discs of a game on a fresh board, checking
+// each one against the rules. Actions such as draw offers don't change the
+// board, so they have no position of their own.
+func BuildReplay(record *GameRecord) (*Replay, error) {
+	board := engine.NewStandardBoard()
+	discs := discMoves(record.Moves)
+	replay := &Replay{
+		Game:      record,
+		Result:    record.WinnerNumber(),
+		Positions: make([]ReplayPosition, 0, len(discs)+1),
+	}
+	replay.Positions = append(replay.Positions, ReplayPosition{Board: board.Grid()})
+
+	for i := range discs {
+		move := &discs[i]
+		row, err := board.Drop(move.Column, move.Player)
+		if err != nil {
+			return nil, fmt.Errorf("move %d: %w", i+1, err)
+		}
+		if row != move.Row {
+			return nil, fmt.Errorf("move %d: recorded in row %d but lands in row %d", i+1, move.Row, row)
+		}
+		replay.Positions = append(replay.Positions, ReplayPosition{
+			MoveNumber: i + 1,
+			Move:       move,
+			Board:      board.Grid(),
+			Elapsed:    move.Time.Sub(record.StartTime).Seconds(),
+		})
+	}
+	return replay, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ThinkingTime decides how long a bot waits before playing the move it has
+// chosen, so that it doesn't answer instantly. Headless games use NoThinking.
+type ThinkingTime interface {
+	// Delay returns the time to wait given how long choosing the move took.
+	Delay(searched time.Duration, rng *rand.Rand) time.Duration
+}
+
+// NoThinking plays moves as soon as they are chosen.
+type NoThinking struct{}
+
+// Delay implements ThinkingTime.
+func (NoThinking) Delay(time.Duration, *rand.Rand) time.Duration { return 0 }
+
+// FixedThinking waits the same time before every move.
+type FixedThinking time.Duration
+
+// Delay implements ThinkingTime.
+func (f FixedThinking) Delay(time.Duration, *rand.Rand) time.Duration { return time.Duration(f) }
+
+// RandomThinking waits a uniformly random time between Min and Max.
+type RandomThinking struct {
+	Min, Max time.Duration
+}
+
+// Delay implements ThinkingTime.
+func (r RandomThinking) Delay(_ time.Duration, rng *rand.Rand) time.Duration {
+	if r.Max <= r.Min {
+		return r.Min
+	}
+	return r.Min + time.Duration(rng.Int63n(int64(r.Max-r.Min)))
+}
+
+// ProportionalThinking waits Factor times as long as the move took to
+// choose, so hard positions look hard, capped at Max.
+type ProportionalThinking struct {
+	Factor float64
+	Max    time.Duration // Zero means no cap
+}
+
+// Delay implements ThinkingTime.
+func (p ProportionalThinking) Delay(searched time.Duration, _ *rand.Rand) time.Duration {
+	d := time.Duration(p.Factor * float64(searched))
+	if p.Max > 0 && d > p.Max {
+		return p.Max
+	}
+	return d
+}
+
+// defaultThinking is the human-feeling pace bots play at in production.
+var defaultThinking ThinkingTime = RandomThinking{Min: 500 * time.Millisecond, Max: 1500 * time.Millisecond}
+
+// maxProportionalThinking caps the delay of a ProportionalThinking parsed
+// from a string.
+const maxProportionalThinking = 3 * time.Second
+
+// ParseThinkingTime parses a thinking time policy: "none", a duration such
+// as "800ms", a range such as "500ms-1.5s", or a factor of the search time
+// such as "2x".
+func ParseThinkingTime(s string) (ThinkingTime, error) {
+	s = strings.TrimSpace(s)
+	if s == "none" || s == "0" {
+		return NoThinking{}, nil
+	}
+	if factor, ok := strings.CutSuffix(s, "x"); ok {
+		f, err := strconv.ParseFloat(factor, 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("invalid thinking time factor %q", s)
+		}
+		return ProportionalThinking{Factor: f, Max: maxProportionalThinking}, nil
+	}
+	if lo, hi, ok := strings.Cut(s, "-"); ok {
+		from, err1 := time.ParseDuration(lo)
+		to, err2 := time.ParseDuration(hi)
+		if err1 != nil || err2 != nil || from < 0 || to < from {
+			return nil, fmt.Errorf("invalid thinking time range %q", s)
+		}
+		return RandomThinking{Min: from, Max: to}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return nil, fmt.Errorf("invalid thinking time %q", s)
+	}
+	return FixedThinking(d), nil
+}
+
+// thinkingTimeFromEnv reads the bots' thinking time policy from
+// BOT_THINKING_TIME, defaulting to a random 0.5-1.5s.
+func thinkingTimeFromEnv() ThinkingTime {
+	s := os.Getenv("BOT_THINKING_TIME")
+	if s == "" {
+		return defaultThinking
+	}
+	t, err := ParseThinkingTime(s)
+	if err != nil {
+		log.Printf("Ignoring BOT_THINKING_TIME: %v", err)
+		return defaultThinking
+	}
+	return t
+}